@@ -0,0 +1,165 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+)
+
+func (app *Application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readAPIKeyOwner(w, r, dto.PermissionsRead)
+	if !ok {
+		return
+	}
+	keys, err := app.Models.APIKeys.GetAllForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"api_keys": keys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The createAPIKeyHandler() creates an API key for a user. The plaintext key is only
+// sent in this response.
+func (app *Application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readAPIKeyOwner(w, r, dto.PermissionsWrite)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name        string          `json:"name"`
+		Permissions dto.Permissions `json:"permissions"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	key, err := dto.GenerateAPIKey(id, input.Name, input.Permissions)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateAPIKey(v, key); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// A key can only carry permissions which its user holds.
+	held, err := app.Models.Permissions.GetAllForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, code := range key.Permissions {
+		v.Check(held.Include(code), "permissions", "must only contain codes held by the user")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Creating a key for someone else hands out their permissions, so the usual
+	// delegation rules apply.
+	if id != helpers.ContextGetUser(r).ID {
+		reason, err := app.checkGrantable(r, key.Permissions)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if reason != "" {
+			app.forbiddenResponse(w, r, reason)
+			return
+		}
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.APIKeys.Insert(key)
+		if err != nil {
+			return err
+		}
+		// The plaintext key must not end up in the audit log.
+		logged := *key
+		logged.Plaintext = ""
+		return app.audit(m, r, dto.AuditAPIKeyCreate, dto.AuditTargetAPIKey, key.ID, nil, &logged)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"api_key": key}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readAPIKeyOwner(w, r, dto.PermissionsWrite)
+	if !ok {
+		return
+	}
+	keyID, err := helpers.ReadInt64Param(r, "key_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.APIKeys.Delete(keyID, id)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditAPIKeyRevoke, dto.AuditTargetAPIKey, keyID, helpers.Envelope{"user_id": id}, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "API key successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The readAPIKeyOwner() helper reads the ID of the user whose API keys are being
+// managed. Users can manage their own keys, and users holding code can manage
+// anybody's. API keys can't be used to manage API keys. It sends an error response
+// and returns false if the request isn't allowed.
+func (app *Application) readAPIKeyOwner(w http.ResponseWriter, r *http.Request, code string) (int64, bool) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to manage API keys")
+		return 0, false
+	}
+	if id == helpers.ContextGetUser(r).ID {
+		return id, true
+	}
+	permissions, err := app.userPermissions(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return 0, false
+	}
+	if !permissions.Include(code) {
+		app.notPermittedResponse(w, r)
+		return 0, false
+	}
+	return id, true
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/kientink26/go-json-api/cmd/api/config"
 	"github.com/kientink26/go-json-api/internal/data"
 	"github.com/kientink26/go-json-api/internal/mailer"
+	"github.com/kientink26/go-json-api/internal/oidc"
+	"github.com/kientink26/go-json-api/internal/password"
 	"log"
+	"net/http"
 )
 
 type Application struct {
@@ -13,9 +16,27 @@ type Application struct {
 	Logger *log.Logger
 	Models data.Models
 	Mailer mailer.Mailer
+	// OIDC is the identity provider for logging in with OpenID Connect, or nil if it
+	// isn't configured.
+	OIDC *oidc.Provider
+	// Breached is the corpus of breached passwords which new passwords are checked
+	// against, or nil if there isn't one.
+	Breached *password.Corpus
+	// The handler returned by Routes().
+	routes http.Handler
+	// While serving an atomic batch, background work is queued here to run once the
+	// batch is committed, rather than when its changes may still be rolled back.
+	deferred *[]func()
+	// While serving an atomic batch, the models of the connection pool, for changes
+	// which must be kept even if the batch is rolled back.
+	pool *data.Models
 }
 
 func (app *Application) background(fn func()) {
+	if app.deferred != nil {
+		*app.deferred = append(*app.deferred, fn)
+		return
+	}
 	// Launch a background goroutine.
 	go func() {
 		// Recover any panic.
@@ -0,0 +1,69 @@
+package application
+
+import (
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+)
+
+// The audit() helper records a privileged change made by the user of the request.
+// Pass it the models of the transaction which made the change, so that the change
+// and its audit event are committed together. Before and after are the states of the
+// target, either of which may be nil.
+func (app *Application) audit(m data.Models, r *http.Request, action, targetType string, targetID int64, before, after interface{}) error {
+	changes, err := dto.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	// A user's details are personal data, which must be scrubbed when their account is
+	// deleted, so only the names of the fields which changed are recorded.
+	if isUser(before) || isUser(after) {
+		changes = dto.Redact(changes)
+	}
+	event := &dto.AuditEvent{
+		ActorID:    helpers.ContextGetUser(r).ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		RequestID:  helpers.ContextGetRequestID(r),
+		IP:         helpers.ClientIP(r),
+	}
+	return m.Audit.Insert(event)
+}
+
+func isUser(v interface{}) bool {
+	_, ok := v.(*dto.User)
+	return ok
+}
+
+func (app *Application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input dto.AuditFilters
+	v := validator.New()
+	qs := r.URL.Query()
+	input.ActorID = helpers.ReadInt64(qs, "actor_id", 0, v)
+	input.TargetType = helpers.ReadString(qs, "target_type", "")
+	input.TargetID = helpers.ReadInt64(qs, "target_id", 0, v)
+	input.CreatedAfter = helpers.ReadTime(qs, "created_after", v)
+	input.CreatedBefore = helpers.ReadTime(qs, "created_before", v)
+	input.Page = helpers.ReadInt(qs, "page", 1, v)
+	input.PageSize = helpers.ReadInt(qs, "page_size", 20, v)
+	// The most recent events come first by default.
+	input.Sort = helpers.ReadString(qs, "sort", "-id")
+	input.SortSafelist = []string{"id", "-id"}
+	if dto.ValidateAuditFilters(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	events, metadata, err := app.Models.Audit.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
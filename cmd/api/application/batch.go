@@ -0,0 +1,122 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"net/http/httptest"
+)
+
+// errBatchFailed is used to roll back an atomic batch when one of its sub-requests
+// fails.
+var errBatchFailed = errors.New("batch sub-request failed")
+
+// The batchHandler() runs several requests in one round trip. Each sub-request goes
+// through the full router and middleware chain with the caller's credentials, in
+// order. In atomic mode the sub-requests share one database transaction, processing
+// stops at the first one which fails, and all of their changes are rolled back.
+func (app *Application) batchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Atomic   bool               `json:"atomic"`
+		Requests []dto.BatchRequest `json:"requests"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateBatch(v, input.Requests); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	responses := []*dto.BatchResponse{}
+	status := http.StatusOK
+	if !input.Atomic {
+		for i, request := range input.Requests {
+			responses = append(responses, app.dispatch(app.routes, r, i, request))
+		}
+	} else {
+		// A copy of the application, with its own routes, serves the batch bound to
+		// the transaction.
+		var deferred []func()
+		tx := *app
+		tx.deferred = &deferred
+		tx.pool = &app.Models
+		handler := tx.Routes()
+		err = app.Models.Transaction(func(m data.Models) error {
+			tx.Models = m
+			for i, request := range input.Requests {
+				response := app.dispatch(handler, r, i, request)
+				responses = append(responses, response)
+				if response.Status >= http.StatusBadRequest {
+					status = response.Status
+					return errBatchFailed
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchFailed) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if err == nil {
+			// The background work of the sub-requests, such as sending emails, only
+			// starts once their changes are committed. It uses the connection pool, as
+			// the transaction is over.
+			tx.Models = app.Models
+			tx.deferred = nil
+			tx.pool = nil
+			for _, fn := range deferred {
+				app.background(fn)
+			}
+		}
+	}
+	env := helpers.Envelope{"responses": responses}
+	if input.Atomic {
+		env["committed"] = err == nil
+	}
+	err = helpers.WriteJSON(w, status, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The dispatch() helper serves the i-th sub-request of the batch request r and
+// records its response.
+func (app *Application) dispatch(handler http.Handler, r *http.Request, i int, request dto.BatchRequest) *dto.BatchResponse {
+	sub, err := http.NewRequestWithContext(r.Context(), request.Method, request.Path, bytes.NewReader(request.Body))
+	if err != nil {
+		return &dto.BatchResponse{Status: http.StatusBadRequest, Body: helpers.Envelope{"error": err.Error()}}
+	}
+	sub.RemoteAddr = r.RemoteAddr
+	if len(request.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range request.Headers {
+		sub.Header.Set(name, value)
+	}
+	// The sub-requests run as the caller, and can be traced back to the batch.
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		sub.Header.Set("Authorization", authorization)
+	}
+	sub.Header.Set("X-Request-ID", fmt.Sprintf("%s-%d", helpers.ContextGetRequestID(r), i))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, sub)
+	response := &dto.BatchResponse{Status: rec.Code, Headers: rec.Header()}
+	body := rec.Body.Bytes()
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		response.Body = json.RawMessage(body)
+	default:
+		response.Body = string(body)
+	}
+	return response
+}
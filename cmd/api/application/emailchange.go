@@ -0,0 +1,208 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"time"
+)
+
+// How long the confirmation token for a new email address is valid for.
+const emailChangeTTL = 24 * time.Hour
+
+// The requestEmailChangeHandler() starts changing the user's email address. A token
+// is sent to the new address, which confirmEmailChangeHandler() takes to make the
+// change, and the old address is told about it.
+func (app *Application) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to change the email address")
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	dto.ValidateEmail(v, input.Email)
+	v.Check(input.Email != user.Email, "email", "must be different from your current email address")
+	v.Check(input.Password != "", "password", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !app.checkCurrentPassword(w, r, v, "password", user, input.Password) {
+		return
+	}
+	_, err = app.Models.Users.GetByEmail(input.Email)
+	switch {
+	case err == nil:
+		v.AddError("email", "a user with this email address already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case !errors.Is(err, postgresql.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Only the latest request can be confirmed.
+	err = app.Models.Tokens.DeleteAllForUser(dto.ScopeEmailChange, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	token, err := app.Models.Tokens.NewWithData(user.ID, emailChangeTTL, dto.ScopeEmailChange, input.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.background(func() {
+		err := app.Mailer.Send(input.Email, "email_change_confirm.gohtml", map[string]interface{}{
+			"emailChangeToken": token.Plaintext,
+		})
+		if err != nil {
+			app.logError(err)
+		}
+		err = app.Mailer.Send(user.Email, "email_change_notice.gohtml", map[string]interface{}{
+			"newEmail": input.Email,
+		})
+		if err != nil {
+			app.logError(err)
+		}
+	})
+	env := helpers.Envelope{"message": "an email will be sent to the new address containing confirmation instructions"}
+	err = helpers.WriteJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The confirmEmailChangeHandler() changes the user's email address to the one
+// confirmed by the token. Every session of the user is logged out and their API keys
+// are revoked, and a new authentication token for this one is sent in the response.
+func (app *Application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to change the email address")
+		return
+	}
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	token, err := app.Models.Tokens.Get(dto.ScopeEmailChange, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if token.UserID != helpers.ContextGetUser(r).ID {
+		v.AddError("token", "incorrect email change token for this user")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	var authToken *dto.Token
+	var user *dto.User
+	err = app.Models.Transaction(func(m data.Models) error {
+		var err error
+		user, err = m.Users.Get(token.UserID)
+		if err != nil {
+			return err
+		}
+		before := *user
+		user.Email = token.Data
+		// The address may have been taken since the change was requested, which
+		// Update() reports as ErrDuplicateEmail.
+		err = m.Users.Update(user)
+		if err != nil {
+			return err
+		}
+		for _, scope := range []string{dto.ScopeEmailChange, dto.ScopeAuthentication, dto.ScopeTwoFactorPending} {
+			err = m.Tokens.DeleteAllForUser(scope, user.ID)
+			if err != nil {
+				return err
+			}
+		}
+		err = m.APIKeys.DeleteAllForUser(user.ID)
+		if err != nil {
+			return err
+		}
+		authToken, err = m.Tokens.NewSession(user.ID, 24*time.Hour, helpers.ClientIP(r), helpers.UserAgent(r))
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditUserEmailChange, dto.AuditTargetUser, user.ID, &before, user)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"user": user, "authentication_token": authToken}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The checkCurrentPassword() helper checks the password of the user making the
+// request, for changes which need them to confirm it, adding a validation error for
+// the field if it is wrong. Wrong passwords count as failed logins, so that a stolen
+// session can't be used to guess it, and are throttled in the same way. It reports
+// whether the password is correct; if it isn't, a response has been sent.
+func (app *Application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, v *validator.Validator, field string, user *dto.User, plaintextPassword string) bool {
+	retryAfter, err := app.loginRetryAfter(r, user.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	if retryAfter > 0 {
+		app.loginThrottledResponse(w, r, retryAfter)
+		return false
+	}
+	match, err := user.Password.Matches(plaintextPassword)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !match {
+		err = app.recordLoginFailure(r, user.Email, user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+		v.AddError(field, "is incorrect")
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+	return true
+}
@@ -3,8 +3,12 @@ package application
 import (
 	"fmt"
 	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // logging an error message
@@ -77,7 +81,55 @@ func (app *Application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 
+// The forbiddenResponse() method is used when the user has the permission needed for
+// a request but the particular change isn't allowed, with the reason why.
+func (app *Application) forbiddenResponse(w http.ResponseWriter, r *http.Request, reason string) {
+	app.errorResponse(w, r, http.StatusForbidden, reason)
+}
+
 func (app *Application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+// The unsupportedMediaTypeResponse() method is used when a PATCH request body isn't in
+// one of the accepted formats, which are advertised in the Accept-Patch header.
+func (app *Application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, accepted []string) {
+	w.Header().Set("Accept-Patch", strings.Join(accepted, ", "))
+	message := fmt.Sprintf("the request body must be one of: %s", strings.Join(accepted, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *Application) patchTestFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the patch was not applied because a test operation failed"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *Application) idempotencyKeyInUseResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a request with this Idempotency-Key is still being processed, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *Application) idempotencyKeyMismatchResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this Idempotency-Key has already been used for a different request"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *Application) twoFactorEnabledResponse(w http.ResponseWriter, r *http.Request) {
+	message := "two-factor authentication is already enabled for this account"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *Application) dataExportExistsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a data export is already being prepared or ready to download"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// The loginThrottledResponse() method is used when there have been too many failed
+// logins for the email address or client IP, with how long to wait in Retry-After.
+func (app *Application) loginThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	message := fmt.Sprintf("too many failed login attempts, please try again in %d seconds", seconds)
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
@@ -0,0 +1,205 @@
+package application
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The createDataExportHandler() starts assembling an archive of the personal data of
+// the user making the request. The user is sent an email with a download token when
+// it is ready. Only one export can be pending or ready at a time.
+func (app *Application) createDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to export personal data")
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	export := &dto.DataExport{
+		UserID: user.ID,
+		Expiry: time.Now().Add(app.Config.Export.TTL),
+	}
+	err := app.Models.Exports.Insert(export)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrExportExists):
+			app.dataExportExistsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.background(func() {
+		app.completeDataExport(export, user)
+	})
+	err = helpers.WriteJSON(w, http.StatusAccepted, helpers.Envelope{"export": export}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The showDataExportHandler() returns the status of one of the user's exports or,
+// given the download token from the email as the token query string parameter, the
+// archive itself.
+func (app *Application) showDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to export personal data")
+		return
+	}
+	id, err := helpers.ReadInt64Param(r, "export_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	export, err := app.Models.Exports.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	tokenPlaintext := r.URL.Query().Get("token")
+	if tokenPlaintext == "" {
+		err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"export": export}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	v := validator.New()
+	if dto.ValidateTokenPlaintext(v, tokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	token, err := app.Models.Tokens.Get(dto.ScopeDataExport, tokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired download token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if token.UserID != user.ID || token.Data != strconv.FormatInt(export.ID, 10) || export.Status != dto.ExportReady {
+		v.AddError("token", "incorrect download token for this export")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="greenlight-export-%d.zip"`, export.ID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Archive)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Archive)
+}
+
+// The completeDataExport() helper assembles the archive of a pending export and
+// emails its download token to the user. A failed export is kept, so that the user
+// can see that it failed.
+func (app *Application) completeDataExport(export *dto.DataExport, user *dto.User) {
+	archive, err := app.buildDataExport(user.ID)
+	if err != nil {
+		app.logError(err)
+	}
+	export.Archive = archive
+	err = app.Models.Exports.Complete(export)
+	if err != nil {
+		app.logError(err)
+		return
+	}
+	if export.Status != dto.ExportReady {
+		return
+	}
+	token, err := app.Models.Tokens.NewWithData(user.ID, time.Until(export.Expiry), dto.ScopeDataExport, strconv.FormatInt(export.ID, 10))
+	if err != nil {
+		app.logError(err)
+		return
+	}
+	data := map[string]interface{}{
+		"exportID":      export.ID,
+		"downloadToken": token.Plaintext,
+		"expiry":        export.Expiry.UTC().Format(time.RFC1123),
+	}
+	err = app.Mailer.Send(user.Email, "data_export_ready.gohtml", data)
+	if err != nil {
+		app.logError(err)
+	}
+}
+
+// The buildDataExport() helper returns a ZIP archive holding a JSON file for each
+// kind of personal data kept about a user.
+func (app *Application) buildDataExport(userID int64) ([]byte, error) {
+	user, err := app.Models.Users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := app.Models.Permissions.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := app.Models.Permissions.GetGrantsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := app.Models.Roles.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := app.Models.Comments.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := app.Models.Tokens.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := app.Models.APIKeys.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		name string
+		data helpers.Envelope
+	}{
+		{"profile.json", helpers.Envelope{"user": user}},
+		{"permissions.json", helpers.Envelope{"permissions": permissions, "grants": grants, "roles": roles}},
+		{"comments.json", helpers.Envelope{"comments": comments}},
+		{"tokens.json", helpers.Envelope{"tokens": tokens}},
+		{"api_keys.json", helpers.Envelope{"api_keys": keys}},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		js, err := json.MarshalIndent(file.data, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(append(js, '\n'))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+)
+
+func (app *Application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	filter := dto.Filters{}
+	v := validator.New()
+	qs := r.URL.Query()
+	filter.Sort = helpers.ReadString(qs, "sort", "name")
+	filter.SortSafelist = []string{"name", "movie_count", "-name", "-movie_count"}
+	if dto.ValidateSortQuery(v, filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	genres, err := app.Models.Genres.GetAll(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	genre := &dto.Genre{Name: input.Name}
+	v := validator.New()
+	if dto.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Genres.Insert(genre)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditGenreCreate, dto.AuditTargetGenre, genre.ID, nil, genre)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrDuplicateGenre):
+			v.AddError("name", "a genre with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%d", genre.ID))
+	err = helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"genre": genre}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The renameGenreHandler() changes the name of a genre. Every movie using the genre is
+// rewritten to use the new name.
+func (app *Application) renameGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	genre, err := app.Models.Genres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Name string `json:"name"`
+	}
+	err = helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	before := *genre
+	genre.Name = input.Name
+	v := validator.New()
+	if dto.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Genres.Rename(genre, before.Name)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditGenreRename, dto.AuditTargetGenre, genre.ID, &before, genre)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrDuplicateGenre):
+			v.AddError("name", "a genre with this name already exists, merge the genres instead")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The mergeGenreHandler() folds the genre in the URL into another genre, rewriting
+// every movie which used it, and removes it from the catalog.
+func (app *Application) mergeGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Into int64 `json:"into"`
+	}
+	err = helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(input.Into > 0, "into", "must be provided")
+	v.Check(input.Into != id, "into", "must be a different genre")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	source, err := app.Models.Genres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	target, err := app.Models.Genres.Get(input.Into)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			v.AddError("into", "genre does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// The audit event records the source genre becoming the target.
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Genres.Merge(source, target)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditGenreMerge, dto.AuditTargetGenre, source.ID, source, target)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"genre": target}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The resolveMovieGenres() helper checks the genres of a movie against the genre
+// catalog and replaces them with their catalog spelling. Any problem is recorded in
+// the validator.
+func (app *Application) resolveMovieGenres(v *validator.Validator, movie *dto.Movie) error {
+	resolved, unknown, err := app.Models.Genres.Resolve(movie.Genres)
+	if err != nil {
+		return err
+	}
+	if dto.ValidateMovieGenres(v, unknown); !v.Valid() {
+		return nil
+	}
+	// Different spellings of the same genre resolve to a single catalog entry.
+	v.Check(validator.Unique(resolved), "genres", "must not contain duplicate values")
+	movie.Genres = resolved
+	return nil
+}
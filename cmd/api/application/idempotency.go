@@ -0,0 +1,137 @@
+package application
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// The idempotent() middleware makes a create endpoint safe to retry. The response to
+// the first request carrying an Idempotency-Key header is stored, and later requests
+// with the same key from the same user (or, for anonymous users, the same client
+// address) get that response replayed instead of running the handler again. Requests
+// without the header are passed straight through.
+func (app *Application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		v := validator.New()
+		if dto.ValidateIdempotencyKey(v, key); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		// The body is read up front so that it can be compared with the original
+		// request, then put back for the handler.
+		maxBytes := 1_048_576
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytes))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		hash.Write(body)
+		requestHash := hash.Sum(nil)
+
+		// Anonymous users all share the same user ID, so their keys are scoped to the
+		// client address as well, to keep one client from replaying the response
+		// (and the personal data in it) sent to another.
+		user := helpers.ContextGetUser(r)
+		if user.IsAnonymous() {
+			key = helpers.ClientIP(r) + " " + key
+		}
+		record, err := app.Models.Idempotency.Reserve(user.ID, key, requestHash, app.Config.Idempotency.TTL)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if record != nil {
+			switch {
+			case !bytes.Equal(record.RequestHash, requestHash):
+				app.idempotencyKeyMismatchResponse(w, r)
+			case record.Status == 0:
+				app.idempotencyKeyInUseResponse(w, r)
+			default:
+				for name, values := range record.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Status)
+				w.Write(record.Body)
+			}
+			return
+		}
+
+		// If the handler doesn't produce a response worth keeping (because it failed
+		// with a server error or panicked) the key is released so the client can retry.
+		completed := false
+		defer func() {
+			if !completed {
+				if err := app.Models.Idempotency.Release(user.ID, key); err != nil {
+					app.logError(err)
+				}
+			}
+		}()
+		rec := &responseRecorder{ResponseWriter: w, before: w.Header().Clone()}
+		next(rec, r)
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+		err = app.Models.Idempotency.Complete(&dto.IdempotencyKey{
+			UserID: user.ID,
+			Key:    key,
+			Status: rec.status,
+			Header: rec.header,
+			Body:   rec.body.Bytes(),
+		})
+		if err != nil {
+			app.logError(err)
+			return
+		}
+		completed = true
+	}
+}
+
+// responseRecorder passes a response through to the client while keeping a copy of
+// it. Only the headers set by the handler are recorded, not those which the
+// middleware set beforehand (such as X-Request-ID).
+type responseRecorder struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+		rr.header = make(http.Header)
+		for name, values := range rr.ResponseWriter.Header() {
+			if !reflect.DeepEqual(rr.before[name], values) {
+				rr.header[name] = values
+			}
+		}
+		// The length of the replayed body is set by the server.
+		rr.header.Del("Content-Length")
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
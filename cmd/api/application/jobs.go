@@ -0,0 +1,136 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+// StartJobs launches the periodic maintenance jobs in the background.
+func (app *Application) StartJobs() {
+	app.every(time.Hour, app.purgeTrashedMovies)
+	app.every(time.Hour, app.deleteExpiredIdempotencyKeys)
+	app.every(time.Hour, app.deleteExpiredPermissions)
+	app.every(time.Hour, app.deleteExpiredOIDCLogins)
+	app.every(time.Hour, app.deleteStaleLoginFailures)
+	app.every(time.Hour, app.deleteExpiredDataExports)
+	app.every(time.Hour, app.anonymizeDeletedUsers)
+}
+
+// The every() helper runs fn in a background goroutine straight away and then once per
+// interval. Errors and panics are logged without stopping the schedule.
+func (app *Application) every(interval time.Duration, fn func() error) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			app.runJob(fn)
+			<-ticker.C
+		}
+	})
+}
+
+func (app *Application) runJob(fn func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logError(fmt.Errorf("%s", err))
+		}
+	}()
+	if err := fn(); err != nil {
+		app.logError(err)
+	}
+}
+
+// Permanently delete the movies which have been in the trash for longer than the
+// retention window.
+func (app *Application) purgeTrashedMovies() error {
+	purged, err := app.Models.Movies.Purge(time.Now().Add(-app.Config.Trash.Retention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		app.Logger.Printf("purged %d movies from the trash", purged)
+	}
+	return nil
+}
+
+// Delete the stored responses to requests whose idempotency keys have expired.
+func (app *Application) deleteExpiredIdempotencyKeys() error {
+	deleted, err := app.Models.Idempotency.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		app.Logger.Printf("deleted %d expired idempotency keys", deleted)
+	}
+	return nil
+}
+
+// Delete the permission grants which have expired. Expired grants are already ignored
+// by permission checks, so this only keeps the table tidy.
+func (app *Application) deleteExpiredPermissions() error {
+	deleted, err := app.Models.Permissions.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		app.Logger.Printf("deleted %d expired permission grants", deleted)
+	}
+	return nil
+}
+
+// Delete the OpenID Connect logins which were started but never completed.
+func (app *Application) deleteExpiredOIDCLogins() error {
+	_, err := app.Models.OIDC.DeleteExpiredLogins()
+	return err
+}
+
+// Delete the counts of failed logins which are outside the lockout window and no
+// longer locked.
+func (app *Application) deleteStaleLoginFailures() error {
+	deleted, err := app.Models.Logins.DeleteStale(time.Now().Add(-app.Config.Lockout.Window))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		app.Logger.Printf("deleted %d stale login failure counts", deleted)
+	}
+	return nil
+}
+
+// Delete the data exports which can no longer be downloaded.
+func (app *Application) deleteExpiredDataExports() error {
+	deleted, err := app.Models.Exports.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		app.Logger.Printf("deleted %d expired data exports", deleted)
+	}
+	return nil
+}
+
+// Anonymize the users whose grace period for deleting their account has passed.
+func (app *Application) anonymizeDeletedUsers() error {
+	ids, err := app.Models.Users.GetDueForDeletion()
+	if err != nil {
+		return err
+	}
+	anonymized := 0
+	for _, id := range ids {
+		err = app.Models.Users.Anonymize(id)
+		switch {
+		case err == nil:
+			anonymized++
+		// The user cancelled the deletion in the meantime.
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+		default:
+			return err
+		}
+	}
+	if anonymized > 0 {
+		app.Logger.Printf("anonymized %d deleted users", anonymized)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"net/http"
+	"time"
+)
+
+// The lockoutPolicy() helper returns the configured lockout policy for a scope.
+func (app *Application) lockoutPolicy(scope string) dto.LockoutPolicy {
+	policy := dto.LockoutPolicy{
+		Threshold: app.Config.Lockout.Threshold,
+		Window:    app.Config.Lockout.Window,
+		Duration:  app.Config.Lockout.Duration,
+	}
+	if scope == dto.LoginFailureScopeIP {
+		policy.Threshold = app.Config.Lockout.IPThreshold
+	}
+	return policy
+}
+
+// The loginFailures() helper returns the model for failed logins. In an atomic batch
+// it is bound to the connection pool rather than the transaction, since a wrong
+// password fails the sub-request and rolls the batch back, which mustn't take the
+// record of the failure with it.
+func (app *Application) loginFailures() postgresql.LoginFailureModel {
+	if app.pool != nil {
+		return app.pool.Logins
+	}
+	return app.Models.Logins
+}
+
+// The loginRetryAfter() helper returns how long the client must wait before it can
+// try to log in with the email address, which is zero if it can try now.
+func (app *Application) loginRetryAfter(r *http.Request, email string) (time.Duration, error) {
+	var retryAfter time.Duration
+	keys := map[string]string{
+		dto.LoginFailureScopeEmail: dto.LoginFailureEmailKey(email),
+		dto.LoginFailureScopeIP:    helpers.ClientIP(r),
+	}
+	for scope, key := range keys {
+		f, err := app.loginFailures().Get(scope, key)
+		if err != nil {
+			if errors.Is(err, postgresql.ErrRecordNotFound) {
+				continue
+			}
+			return 0, err
+		}
+		if wait := f.RetryAfter(app.lockoutPolicy(scope), time.Now()); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+// The recordLoginFailure() helper counts a failed login against the email address and
+// the client IP. The user is nil if there is no user with the address. When the
+// failure locks the address of a user, they are sent an email about it.
+func (app *Application) recordLoginFailure(r *http.Request, email string, user *dto.User) error {
+	_, err := app.loginFailures().Record(dto.LoginFailureScopeIP, helpers.ClientIP(r), app.lockoutPolicy(dto.LoginFailureScopeIP))
+	if err != nil {
+		return err
+	}
+	policy := app.lockoutPolicy(dto.LoginFailureScopeEmail)
+	f, err := app.loginFailures().Record(dto.LoginFailureScopeEmail, dto.LoginFailureEmailKey(email), policy)
+	if err != nil {
+		return err
+	}
+	if user != nil && f.JustLocked(policy) && f.LockedUntil != nil {
+		app.background(func() {
+			data := map[string]interface{}{
+				"failures":    f.Failures,
+				"lockedUntil": f.LockedUntil.UTC().Format(time.RFC1123),
+			}
+			err := app.Mailer.Send(user.Email, "account_locked.gohtml", data)
+			if err != nil {
+				app.logError(err)
+			}
+		})
+	}
+	return nil
+}
+
+// The unlockUserHandler() clears the failed logins for a user's email address, so
+// that they can log in again straight away.
+func (app *Application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Logins.ResetForUser(id)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditUserUnlock, dto.AuditTargetUser, id, nil, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "user successfully unlocked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/kientink26/go-json-api/cmd/api/helpers"
@@ -8,9 +10,12 @@ import (
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
 	"github.com/kientink26/go-json-api/internal/validator"
 	"net/http"
+	"regexp"
 	"strings"
 )
 
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
 func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -29,6 +34,27 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// The requestID() middleware gives every request an ID, which is sent back in the
+// X-Request-ID header and recorded in the audit log. An ID supplied by the client (or
+// a proxy in front of us) is kept if it looks sensible.
+func (app *Application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if len(id) == 0 || len(id) > 128 || !validator.Matches(id, requestIDRX) {
+			randomBytes := make([]byte, 16)
+			_, err := rand.Read(randomBytes)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			id = hex.EncodeToString(randomBytes)
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = helpers.ContextSetRequestID(r, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *Application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -44,8 +70,12 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 		// Otherwise, we expect the value of the Authorization header to be in the format
-		// "Bearer <token>"
+		// "Bearer <token>" or "ApiKey <key>"
 		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) == 2 && headerParts[0] == "ApiKey" {
+			app.authenticateAPIKey(w, r, headerParts[1], next)
+			return
+		}
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
@@ -56,7 +86,7 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
-		user, err := app.Models.Users.GetForToken(dto.ScopeAuthentication, token)
+		session, user, err := app.Models.Tokens.GetForSession(token, helpers.ClientIP(r))
 		if err != nil {
 			switch {
 			case errors.Is(err, postgresql.ErrRecordNotFound):
@@ -69,11 +99,36 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		// Call the contextSetUser() helper to add the user information to the request
 		// context.
 		r = helpers.ContextSetUser(r, user)
+		r = helpers.ContextSetSession(r, session)
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
 	})
 }
 
+// The authenticateAPIKey() helper authenticates a request made with an API key as the
+// key's user, and records the key in the request context so that the permissions of
+// the request can be limited to those of the key.
+func (app *Application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, plaintext string, next http.Handler) {
+	v := validator.New()
+	if dto.ValidateAPIKeyPlaintext(v, plaintext); !v.Valid() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+	key, user, err := app.Models.APIKeys.GetForKey(plaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	r = helpers.ContextSetUser(r, user)
+	r = helpers.ContextSetAPIKey(r, key)
+	next.ServeHTTP(w, r)
+}
+
 // Create a new requireAuthenticatedUser() middleware to check that a user is not
 // anonymous.
 func (app *Application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
@@ -104,29 +159,74 @@ func (app *Application) requireActivatedUser(next http.HandlerFunc) http.Handler
 }
 
 func (app *Application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAnyPermission([]string{code}, next)
+}
+
+// The requireAnyPermission() middleware checks that the user has at least one of the
+// permission codes. It is used for endpoints where the handler makes a finer-grained
+// check itself, such as with authorizeOwner().
+func (app *Application) requireAnyPermission(codes []string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		// Retrieve the user from the request context.
-		user := helpers.ContextGetUser(r)
-		// Get the slice of permissions for the user.
-		permissions, err := app.Models.Permissions.GetAllForUser(user.ID)
+		// Get the slice of permissions for the request.
+		permissions, err := app.userPermissions(r)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
-		// Check if the slice includes the required permission. If it doesn't, then
-		// return a 403 Forbidden response.
-		if !permissions.Include(code) {
-			app.notPermittedResponse(w, r)
-			return
+		// Check if the slice includes one of the required permissions. If it doesn't,
+		// then return a 403 Forbidden response.
+		for _, code := range codes {
+			if permissions.Include(code) {
+				// They have a required permission so we call the next handler in the
+				// chain.
+				next.ServeHTTP(w, r)
+				return
+			}
 		}
-		// Otherwise they have the required permission so we call the next handler in
-		// the chain.
-		next.ServeHTTP(w, r)
+		app.notPermittedResponse(w, r)
 	}
 	// Wrap this with the requireActivatedUser() middleware before returning it.
 	return app.requireActivatedUser(fn)
 }
 
+// The authorizeOwner() helper makes an object-level check for a resource belonging
+// to the user ownerID. Users holding code may act on any such resource, while users
+// holding only ownCode may act on their own. Resources without an owner (ownerID 0)
+// need code.
+func (app *Application) authorizeOwner(r *http.Request, code, ownCode string, ownerID int64) (bool, error) {
+	user := helpers.ContextGetUser(r)
+	permissions, err := app.userPermissions(r)
+	if err != nil {
+		return false, err
+	}
+	if permissions.Include(code) {
+		return true, nil
+	}
+	return permissions.Include(ownCode) && ownerID != 0 && ownerID == user.ID, nil
+}
+
+// The userPermissions() helper returns the permission codes of the user making the
+// request. A request made with an API key only gets the codes which the user holds
+// and which were also given to the key.
+func (app *Application) userPermissions(r *http.Request) (dto.Permissions, error) {
+	user := helpers.ContextGetUser(r)
+	permissions, err := app.Models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	key := helpers.ContextGetAPIKey(r)
+	if key == nil {
+		return permissions, nil
+	}
+	allowed := dto.Permissions{}
+	for _, code := range permissions {
+		if key.Permissions.Include(code) {
+			allowed = append(allowed, code)
+		}
+	}
+	return allowed, nil
+}
+
 func (app *Application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -140,7 +240,7 @@ func (app *Application) enableCORS(next http.Handler) http.Handler {
 			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 				// Set the necessary preflight response headers
 				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
 				// Write the headers along with a 200 OK status and return from
 				// the middleware with no further action.
 				w.WriteHeader(http.StatusOK)
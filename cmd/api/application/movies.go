@@ -4,23 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/jsonpatch"
 	"github.com/kientink26/go-json-api/internal/validator"
 	"net/http"
 )
 
 func (app *Application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
-		dto.Filters
+		Facets []string
+		dto.MovieFilters
 	}
 	v := validator.New()
 	// Call r.URL.Query() to get the url.Values map containing the query string data.
 	qs := r.URL.Query()
 	input.Title = helpers.ReadString(qs, "title", "")
+	input.Query = helpers.ReadString(qs, "q", "")
 	input.Genres = helpers.ReadCSV(qs, "genres", []string{})
+	input.YearMin = helpers.ReadInt(qs, "year_min", 0, v)
+	input.YearMax = helpers.ReadInt(qs, "year_max", 0, v)
+	input.RuntimeMin = helpers.ReadInt(qs, "runtime_min", 0, v)
+	input.RuntimeMax = helpers.ReadInt(qs, "runtime_max", 0, v)
+	input.CreatedAfter = helpers.ReadTime(qs, "created_after", v)
+	input.CreatedBefore = helpers.ReadTime(qs, "created_before", v)
+	input.Facets = helpers.ReadCSV(qs, "facets", []string{})
 	// We pass the validator instance as the final argument here.
 	input.Page = helpers.ReadInt(qs, "page", 1, v)
 	input.PageSize = helpers.ReadInt(qs, "page_size", 20, v)
@@ -28,22 +37,68 @@ func (app *Application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	// by the client (which will imply a ascending sort on movie ID).
 	input.Sort = helpers.ReadString(qs, "sort", "id")
 	// Add the supported sort values for this endpoint to the sort safelist.
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
-	if dto.ValidateFilters(v, input.Filters); !v.Valid() {
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "relevance", "-id", "-title", "-year", "-runtime"}
+	dto.ValidateMovieFilters(v, input.MovieFilters)
+	dto.ValidateMovieFacets(v, input.Facets)
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	movies, metadata, err := app.Models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.Models.Movies.GetAll(input.MovieFilters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"movies": movies, "metadata": metadata}, nil)
+	env := helpers.Envelope{"movies": movies, "metadata": metadata}
+	// If the full-text search found nothing at all, rather than nothing on this page,
+	// retry it with approximate matching so that misspelled titles still find
+	// something. A search of only negated words has nothing to match approximately.
+	if fuzzy := dto.FuzzyText(input.Title, input.Query); metadata.TotalRecords == 0 && fuzzy != "" {
+		input.Fuzzy = fuzzy
+		input.Title = ""
+		input.Query = ""
+		movies, metadata, err = app.Models.Movies.GetAll(input.MovieFilters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env = helpers.Envelope{"movies": movies, "metadata": metadata, "fuzzy": true}
+	}
+	// Only include the facets in the response when the client asked for some.
+	if len(input.Facets) > 0 {
+		facets, err := app.Models.Movies.GetFacets(input.MovieFilters, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 }
+func (app *Application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	prefix := helpers.ReadString(qs, "prefix", "")
+	limit := helpers.ReadInt(qs, "limit", 10, v)
+	if dto.ValidateAutocomplete(v, prefix, limit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	suggestions, err := app.Models.Movies.Autocomplete(prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"movies": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *Application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := helpers.ReadIDParam(r)
 	if err != nil {
@@ -84,6 +139,8 @@ func (app *Application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Year:    input.Year,
 		Runtime: input.Runtime,
 		Genres:  input.Genres,
+		// Record who added the movie, for the movies:write:own permission.
+		CreatedBy: helpers.ContextGetUser(r).ID,
 	}
 	v := validator.New()
 	dto.ValidateMovie(v, movie)
@@ -91,7 +148,23 @@ func (app *Application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.Models.Movies.Insert(movie)
+	err = app.resolveMovieGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Insert the movie and record it in the audit log in a single transaction.
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Movies.Insert(movie)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditMovieCreate, dto.AuditTargetMovie, movie.ID, nil, movie)
+	})
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -124,28 +197,41 @@ func (app *Application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
-	var input struct {
-		Title   *string      `json:"title"` // This will be nil if there is no corresponding key in the JSON
-		Year    *int32       `json:"year"`
-		Runtime *dto.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
-	}
-	err = helpers.ReadJSON(w, r, &input)
+	allowed, err := app.authorizeOwner(r, dto.MoviesWrite, dto.MoviesWriteOwn, movie.CreatedBy)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.serverErrorResponse(w, r, err)
 		return
 	}
-	if input.Title != nil {
-		movie.Title = *input.Title
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
 	}
-	if input.Year != nil {
-		movie.Year = *input.Year
+	// Keep a copy of the movie as it was for the audit log.
+	before := *movie
+	// The body is either a partial movie, or a JSON Merge Patch or JSON Patch document
+	// which is applied to the stored movie.
+	mediaType, err := helpers.ReadMediaType(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
+	switch mediaType {
+	case "application/json":
+		err = readMovieUpdate(w, r, movie)
+	case "application/merge-patch+json", "application/json-patch+json":
+		err = readMoviePatch(w, r, mediaType, movie)
+	default:
+		app.unsupportedMediaTypeResponse(w, r, movieUpdateMediaTypes)
+		return
 	}
-	if input.Genres != nil {
-		movie.Genres = input.Genres // Note that we don't need to dereference a slice.
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.patchTestFailedResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
 	}
 	v := validator.New()
 	dto.ValidateMovie(v, movie)
@@ -153,7 +239,22 @@ func (app *Application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.Models.Movies.Update(movie)
+	err = app.resolveMovieGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Movies.Update(movie)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditMovieUpdate, dto.AuditTargetMovie, movie.ID, &before, movie)
+	})
 	// Intercept any ErrEditConflict error and call the new editConflictResponse()
 	// helper.
 	if err != nil {
@@ -179,9 +280,34 @@ func (app *Application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.notFoundResponse(w, r)
 		return
 	}
-	// Delete the movie from the database, sending a 404 Not Found response to the
-	// client if there isn't a matching record.
-	err = app.Models.Movies.Delete(id)
+	movie, err := app.Models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	allowed, err := app.authorizeOwner(r, dto.MoviesWrite, dto.MoviesWriteOwn, movie.CreatedBy)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	// Move the movie to the trash, sending a 404 Not Found response to the client if
+	// there isn't a matching record.
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Movies.Delete(id)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditMovieDelete, dto.AuditTargetMovie, id, movie, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, postgresql.ErrRecordNotFound):
@@ -192,7 +318,61 @@ func (app *Application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	// Return a 200 OK status code along with a success message.
-	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "movie successfully deleted"}, nil)
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "movie successfully moved to the trash"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listTrashedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	filters := dto.Filters{}
+	v := validator.New()
+	qs := r.URL.Query()
+	filters.Page = helpers.ReadInt(qs, "page", 1, v)
+	filters.PageSize = helpers.ReadInt(qs, "page_size", 20, v)
+	// The most recently deleted movies come first by default.
+	filters.Sort = helpers.ReadString(qs, "sort", "-deleted_at")
+	filters.SortSafelist = []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"}
+	if dto.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movies, metadata, err := app.Models.Movies.GetAllDeleted(filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Sending a 404 Not Found response to the client if the movie isn't in the trash.
+	var movie *dto.Movie
+	err = app.Models.Transaction(func(m data.Models) error {
+		movie, err = m.Movies.Restore(id)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditMovieRestore, dto.AuditTargetMovie, id, nil, movie)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,238 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/oidc"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// How long a client has to complete a login with the identity provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// The beginOIDCLoginHandler() starts a login with the identity provider. The client
+// sends the user to the returned URL, and the provider redirects them back with a
+// code and the state, which the client passes to oidcCallbackHandler().
+func (app *Application) beginOIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	login := &dto.OIDCLogin{Expiry: time.Now().Add(oidcLoginTTL)}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		var err error
+		*value, err = oidc.GenerateVerifier()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	authorizationURL, err := app.OIDC.AuthCodeURL(login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.Models.OIDC.InsertLogin(login)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	env := helpers.Envelope{"authorization_url": authorizationURL, "state": login.State, "expiry": login.Expiry}
+	err = helpers.WriteJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The oidcCallbackHandler() completes a login with the identity provider and issues
+// an authentication token. A user logging in for the first time is linked to the
+// user with the same email address, or a new activated user is created, as long as
+// the provider has verified the address.
+func (app *Application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateOIDCCallback(v, input.Code, input.State); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	login, err := app.Models.OIDC.TakeLogin(input.State)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			v.AddError("state", "invalid or expired login state")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	claims, err := app.OIDC.Exchange(input.Code, login.Verifier, login.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidToken):
+			app.logError(err)
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var user *dto.User
+	err = app.Models.Transaction(func(m data.Models) error {
+		var err error
+		user, err = app.oidcUser(m, claims)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errEmailNotVerified):
+			app.forbiddenResponse(w, r, "the identity provider has not verified your email address")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// The identity provider stands in for the password, not for the second factor.
+	if app.twoFactorPendingResponse(w, r, user) {
+		return
+	}
+	token, err := app.Models.Tokens.NewSession(user.ID, 24*time.Hour, helpers.ClientIP(r), helpers.UserAgent(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"authentication_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+var errEmailNotVerified = errors.New("email address not verified by the identity provider")
+
+// The oidcUser() helper returns the user for the verified claims of an ID token,
+// linking the identity to a user (and creating the user) on the first login.
+func (app *Application) oidcUser(m data.Models, claims *oidc.Claims) (*dto.User, error) {
+	user, err := m.OIDC.GetUserForIdentity(app.OIDC.Issuer, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, postgresql.ErrRecordNotFound) {
+		return nil, err
+	}
+	v := validator.New()
+	if dto.ValidateEmail(v, claims.Email); !claims.EmailVerified || !v.Valid() {
+		return nil, errEmailNotVerified
+	}
+	user, err = m.Users.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		if !user.Activated {
+			err = app.takeOverUnactivatedUser(m, user)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, postgresql.ErrRecordNotFound):
+		user, err = app.createOIDCUser(m, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	err = m.OIDC.LinkIdentity(app.OIDC.Issuer, claims.Subject, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// The takeOverUnactivatedUser() helper activates a user who registered with an email
+// address that the identity provider has verified, which is all that activation
+// checks. The address wasn't confirmed when they registered, so the account may have
+// been set up by someone else: the password is replaced with a random one and the
+// tokens and API keys are revoked, along with two-factor authentication.
+func (app *Application) takeOverUnactivatedUser(m data.Models, user *dto.User) error {
+	password, err := oidc.GenerateVerifier()
+	if err != nil {
+		return err
+	}
+	err = user.Password.Set(password)
+	if err != nil {
+		return err
+	}
+	user.Activated = true
+	err = m.Users.Update(user)
+	if err != nil {
+		return err
+	}
+	for _, scope := range []string{dto.ScopeActivation, dto.ScopeAuthentication, dto.ScopeTwoFactorPending, dto.ScopeEmailChange, dto.ScopeDataExport} {
+		err = m.Tokens.DeleteAllForUser(scope, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.APIKeys.DeleteAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	err = m.TOTP.Delete(user.ID)
+	if err != nil && !errors.Is(err, postgresql.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// The createOIDCUser() helper creates an activated user for a first OIDC login. The
+// user gets a random password, so they can only log in through the identity provider
+// until they reset it.
+func (app *Application) createOIDCUser(m data.Models, claims *oidc.Claims) (*dto.User, error) {
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if len(name) > 500 {
+		name = name[:500]
+	}
+	user := &dto.User{
+		Name:      name,
+		Email:     claims.Email,
+		Activated: true,
+	}
+	password, err := oidc.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
+	err = user.Password.Set(password)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Users.Insert(user)
+	if err != nil {
+		return nil, err
+	}
+	// New users get the same permissions as when they register.
+	err = m.Permissions.AddForUser(user.ID, dto.CommentsWrite)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,107 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/jsonpatch"
+	"net/http"
+)
+
+// movieUpdateMediaTypes are the formats accepted for the body of a movie update.
+var movieUpdateMediaTypes = []string{
+	"application/json",
+	"application/merge-patch+json",
+	"application/json-patch+json",
+}
+
+// The readMovieUpdate() helper reads a partial movie from the request body and copies
+// the fields which are present onto the movie.
+func readMovieUpdate(w http.ResponseWriter, r *http.Request, movie *dto.Movie) error {
+	var input struct {
+		Title   *string      `json:"title"` // This will be nil if there is no corresponding key in the JSON
+		Year    *int32       `json:"year"`
+		Runtime *dto.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		return err
+	}
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+	if input.Genres != nil {
+		movie.Genres = input.Genres // Note that we don't need to dereference a slice.
+	}
+	return nil
+}
+
+// The readMoviePatch() helper reads a JSON Merge Patch or JSON Patch document from the
+// request body and applies it to the JSON representation of the movie. The id,
+// version and created_by members can be tested but not changed.
+func readMoviePatch(w http.ResponseWriter, r *http.Request, mediaType string, movie *dto.Movie) error {
+	doc, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	switch mediaType {
+	case "application/merge-patch+json":
+		var patch json.RawMessage
+		err = helpers.ReadJSON(w, r, &patch)
+		if err != nil {
+			return err
+		}
+		// A patch which isn't an object would replace the whole movie.
+		if !bytes.HasPrefix(bytes.TrimSpace(patch), []byte("{")) {
+			return errors.New("body must be a JSON object")
+		}
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	default:
+		var ops []jsonpatch.Operation
+		err = helpers.ReadJSON(w, r, &ops)
+		if err != nil {
+			return err
+		}
+		patched, err = jsonpatch.Apply(doc, ops)
+	}
+	if err != nil {
+		return err
+	}
+	var result struct {
+		ID        int64        `json:"id"`
+		Title     string       `json:"title"`
+		Year      int32        `json:"year"`
+		Runtime   *dto.Runtime `json:"runtime"` // A pointer so that null means no runtime
+		Genres    []string     `json:"genres"`
+		Version   int32        `json:"version"`
+		CreatedBy int64        `json:"created_by"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&result)
+	if err != nil {
+		return fmt.Errorf("patched movie is invalid: %v", err)
+	}
+	if result.ID != movie.ID || result.Version != movie.Version || result.CreatedBy != movie.CreatedBy {
+		return errors.New("patch must not change the id, version or created_by of the movie")
+	}
+	movie.Title = result.Title
+	movie.Year = result.Year
+	movie.Runtime = 0
+	if result.Runtime != nil {
+		movie.Runtime = *result.Runtime
+	}
+	movie.Genres = result.Genres
+	return nil
+}
@@ -2,7 +2,9 @@ package application
 
 import (
 	"errors"
+	"fmt"
 	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
 	"github.com/kientink26/go-json-api/internal/validator"
@@ -20,7 +22,14 @@ func (app *Application) getUserPermissionsHandler(w http.ResponseWriter, r *http
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"permissions": p}, nil)
+	// The permissions include those from roles, while the grants are the permissions
+	// given to the user directly, with their expiry times.
+	grants, err := app.Models.Permissions.GetGrantsForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"permissions": p, "grants": grants}, nil)
 }
 
 func (app *Application) addUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,19 +38,39 @@ func (app *Application) addUserPermissionsHandler(w http.ResponseWriter, r *http
 		app.notFoundResponse(w, r)
 		return
 	}
-	var p dto.Permissions
-	err = helpers.ReadJSON(w, r, &p)
+	var grant dto.PermissionGrant
+	err = helpers.ReadJSON(w, r, &grant)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 	v := validator.New()
-	dto.ValidatePermissions(v, p)
+	dto.ValidatePermissionGrant(v, &grant)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.Models.Permissions.AddForUser(id, p...)
+	p := grant.Permissions
+	reason, err := app.checkDelegation(r, id, p)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		if grant.ExpiresAt != nil {
+			err = m.Permissions.AddForUserUntil(id, *grant.ExpiresAt, p...)
+		} else {
+			err = m.Permissions.AddForUser(id, p...)
+		}
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditPermissionsGrant, dto.AuditTargetUser, id, nil, helpers.Envelope{"permissions": p, "expires_at": grant.ExpiresAt})
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, postgresql.ErrRecordNotFound):
@@ -75,7 +104,22 @@ func (app *Application) deleteUserPermissionsHandler(w http.ResponseWriter, r *h
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.Models.Permissions.DeleteForUser(id, p...)
+	reason, err := app.checkDelegation(r, id, p)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Permissions.DeleteForUser(id, p...)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditPermissionsRevoke, dto.AuditTargetUser, id, helpers.Envelope{"permissions": p}, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, postgresql.ErrRecordNotFound):
@@ -87,3 +131,41 @@ func (app *Application) deleteUserPermissionsHandler(w http.ResponseWriter, r *h
 	}
 	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "permissions successfully deleted"}, nil)
 }
+
+// The checkDelegation() helper applies the rules for changing the permissions of the
+// user with the given ID, whether directly or through roles. Users can't change their
+// own permissions, and otherwise the codes are checked by checkGrantable(). It returns
+// the reason the change is forbidden, or the empty string if it is allowed.
+func (app *Application) checkDelegation(r *http.Request, userID int64, codes dto.Permissions) (string, error) {
+	if helpers.ContextGetUser(r).ID == userID {
+		return "you cannot change your own permissions or roles", nil
+	}
+	return app.checkGrantable(r, codes)
+}
+
+// The checkGrantable() helper checks that the caller may hand out or take away the
+// given codes. The super codes can only be granted and revoked by the bootstrap admin,
+// who may grant any code. Everybody else can only grant and revoke codes they hold.
+// Through an API key, the bootstrap admin is also limited to the codes of the key.
+func (app *Application) checkGrantable(r *http.Request, codes dto.Permissions) (string, error) {
+	user := helpers.ContextGetUser(r)
+	bootstrapAdmin := app.Config.Permissions.BootstrapAdmin != 0 && user.ID == app.Config.Permissions.BootstrapAdmin
+	if bootstrapAdmin && helpers.ContextGetAPIKey(r) == nil {
+		return "", nil
+	}
+	for _, code := range codes {
+		if !bootstrapAdmin && validator.In(code, app.Config.Permissions.SuperCodes...) {
+			return fmt.Sprintf("only the bootstrap admin can grant or revoke the %s permission", code), nil
+		}
+	}
+	held, err := app.userPermissions(r)
+	if err != nil {
+		return "", err
+	}
+	for _, code := range codes {
+		if !held.Include(code) {
+			return fmt.Sprintf("you cannot grant or revoke the %s permission because you don't hold it", code), nil
+		}
+	}
+	return "", nil
+}
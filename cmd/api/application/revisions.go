@@ -0,0 +1,187 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"math"
+	"net/http"
+)
+
+func (app *Application) listMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	filters := dto.Filters{}
+	v := validator.New()
+	qs := r.URL.Query()
+	filters.Page = helpers.ReadInt(qs, "page", 1, v)
+	filters.PageSize = helpers.ReadInt(qs, "page_size", 20, v)
+	// The latest revisions come first by default.
+	filters.Sort = helpers.ReadString(qs, "sort", "-version")
+	filters.SortSafelist = []string{"version", "-version"}
+	if dto.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Check that the movie exists, and isn't in the trash.
+	_, err = app.Models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	revisions, metadata, err := app.Models.Revisions.GetAllForMovie(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The showMovieRevisionHandler() returns a movie as it was at one of its versions,
+// along with the changes made by that version.
+func (app *Application) showMovieRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.readMovieRevision(w, r)
+	if !ok {
+		return
+	}
+	// The first revision has no predecessor, so all of its fields are changes.
+	var previous interface{}
+	prev, err := app.Models.Revisions.Get(revision.MovieID, revision.Version-1)
+	switch {
+	case err == nil:
+		previous = prev.MovieContent
+	case !errors.Is(err, postgresql.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	changes, err := dto.Diff(previous, revision.MovieContent)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"revision": revision, "changes": changes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The revertMovieHandler() creates a new version of a movie with the content it had
+// at an earlier version.
+func (app *Application) revertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.readMovieRevision(w, r)
+	if !ok {
+		return
+	}
+	movie, err := app.Models.Movies.Get(revision.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	allowed, err := app.authorizeOwner(r, dto.MoviesWrite, dto.MoviesWriteOwn, movie.CreatedBy)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	before := *movie
+	movie.Title = revision.Title
+	movie.Year = revision.Year
+	movie.Runtime = revision.Runtime
+	movie.Genres = revision.Genres
+	// The old content is validated again, as the rules and the genre catalog may have
+	// changed since.
+	v := validator.New()
+	dto.ValidateMovie(v, movie)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.resolveMovieGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Movies.Update(movie)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditMovieRevert, dto.AuditTargetMovie, movie.ID, &before, movie)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The readMovieRevision() helper fetches the revision identified by the :id and
+// :version URL parameters, sending an error response and returning false if it can't.
+func (app *Application) readMovieRevision(w http.ResponseWriter, r *http.Request) (*dto.MovieRevision, bool) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	version, err := helpers.ReadInt64Param(r, "version")
+	if err != nil || version > math.MaxInt32 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	// Revisions of movies in the trash aren't available.
+	_, err = app.Models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	revision, err := app.Models.Revisions.Get(id, int32(version))
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return revision, true
+}
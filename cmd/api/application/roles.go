@@ -0,0 +1,376 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+)
+
+func (app *Application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.Models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) showRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	role, err := app.Models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string          `json:"name"`
+		Permissions dto.Permissions `json:"permissions"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	role := &dto.Role{Name: input.Name, Permissions: input.Permissions}
+	v := validator.New()
+	if dto.ValidateRole(v, role); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	reason, err := app.checkGrantable(r, role.Permissions)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Roles.Insert(role)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditRoleCreate, dto.AuditTargetRole, role.ID, nil, role)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/roles/%d", role.ID))
+	err = helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"role": role}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The updateRoleHandler() renames a role and/or replaces its permissions. The change
+// applies straight away to every user with the role.
+func (app *Application) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	role, err := app.Models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	before := *role
+	var input struct {
+		Name        *string         `json:"name"`
+		Permissions dto.Permissions `json:"permissions"`
+	}
+	err = helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Name != nil {
+		role.Name = *input.Name
+	}
+	if input.Permissions != nil {
+		role.Permissions = input.Permissions
+	}
+	v := validator.New()
+	if dto.ValidateRole(v, role); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Changing a role grants and revokes its codes for everyone who has it.
+	reason, err := app.checkRoleChange(r, role.ID, roleCodes(&before, role))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Roles.Update(role)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditRoleUpdate, dto.AuditTargetRole, role.ID, &before, role)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	role, err := app.Models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	reason, err := app.checkRoleChange(r, role.ID, role.Permissions)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Roles.Delete(role.ID)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditRoleDelete, dto.AuditTargetRole, role.ID, role, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "role successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) getUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	roles, err := app.Models.Roles.GetAllForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) addUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var names []string
+	err = helpers.ReadJSON(w, r, &names)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateRoleNames(v, names); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	roles, err := app.Models.Roles.GetByNames(names)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	reason, err := app.checkDelegation(r, id, roleCodes(roles...))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Roles.AddForUser(id, names...)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditRolesAssign, dto.AuditTargetUser, id, nil, helpers.Envelope{"roles": names})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, postgresql.ErrUnknownRole):
+			v.AddError("roles", "must only contain existing roles")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, postgresql.ErrRoleAlreadyAssigned):
+			v.AddError("roles", "a role is already assigned to the user")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "roles successfully assigned"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var names []string
+	err = helpers.ReadJSON(w, r, &names)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateRoleNames(v, names); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	roles, err := app.Models.Roles.GetByNames(names)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	reason, err := app.checkDelegation(r, id, roleCodes(roles...))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		app.forbiddenResponse(w, r, reason)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Roles.DeleteForUser(id, names...)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditRolesUnassign, dto.AuditTargetUser, id, helpers.Envelope{"roles": names}, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "roles successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The checkRoleChange() helper applies the rules for changing or deleting the role
+// with the given ID, which grants or revokes the given codes for everyone who has it.
+// Like checkDelegation(), it keeps users from changing their own permissions, so
+// users can't change a role they hold.
+func (app *Application) checkRoleChange(r *http.Request, roleID int64, codes dto.Permissions) (string, error) {
+	held, err := app.Models.Roles.GetAllForUser(helpers.ContextGetUser(r).ID)
+	if err != nil {
+		return "", err
+	}
+	for _, role := range held {
+		if role.ID == roleID {
+			return "you cannot change or delete a role you hold", nil
+		}
+	}
+	return app.checkGrantable(r, codes)
+}
+
+// The roleCodes() helper returns every permission code held by the given roles.
+func roleCodes(roles ...*dto.Role) dto.Permissions {
+	codes := dto.Permissions{}
+	for _, role := range roles {
+		for _, code := range role.Permissions {
+			if !codes.Include(code) {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
@@ -10,23 +10,115 @@ func (app *Application) Routes() http.Handler {
 	router := httprouter.New()
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	// Users with movies:write:own can add movies, and change the ones they added.
+	movieWriters := []string{dto.MoviesWrite, dto.MoviesWriteOwn}
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission(dto.MoviesWrite, app.createMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission(dto.MoviesWrite, app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission(dto.MoviesWrite, app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.static(map[string]http.HandlerFunc{
+		"autocomplete": app.autocompleteMoviesHandler,
+		"trash":        app.requirePermission(dto.MoviesWrite, app.listTrashedMoviesHandler),
+	}, app.showMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requireAnyPermission(movieWriters, app.idempotent(app.createMovieHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requireAnyPermission(movieWriters, app.updateMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requireAnyPermission(movieWriters, app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission(dto.MoviesWrite, app.restoreMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.listMovieRevisionsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions/:version", app.showMovieRevisionHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revisions/:version/revert", app.requireAnyPermission(movieWriters, app.revertMovieHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission(dto.MoviesWrite, app.idempotent(app.createGenreHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission(dto.MoviesWrite, app.renameGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:id/merge", app.requirePermission(dto.MoviesWrite, app.mergeGenreHandler))
 
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/comments", app.requireActivatedUser(app.listCommentsHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/comments", app.requirePermission(dto.CommentsWrite, app.createCommentHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/comments", app.requirePermission(dto.CommentsWrite, app.idempotent(app.createCommentHandler)))
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.idempotent(app.registerUserHandler))
+	// The user making the request is at /v1/users/me, along with their email address,
+	// data exports, scheduled deletion and sessions.
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.showCurrentUserHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/:id", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.updateCurrentUserHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.deleteCurrentUserHandler),
+	}, nil))
 	router.HandlerFunc(http.MethodPut, "/v1/users/:id/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/deletion", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.cancelUserDeletionHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/sessions", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.listSessionsHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/sessions/:session_id", app.static(map[string]http.HandlerFunc{
+		"me": app.requireAuthenticatedUser(app.deleteSessionHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/export", app.static(map[string]http.HandlerFunc{
+		"me": app.requireActivatedUser(app.createDataExportHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/export/:export_id", app.static(map[string]http.HandlerFunc{
+		"me": app.requireActivatedUser(app.showDataExportHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/email", app.static(map[string]http.HandlerFunc{
+		"me": app.requireActivatedUser(app.requestEmailChangeHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/email", app.static(map[string]http.HandlerFunc{
+		"me": app.requireActivatedUser(app.confirmEmailChangeHandler),
+	}, nil))
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/2fa", app.createTwoFactorTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/oidc", app.beginOIDCLoginHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/oidc/callback", app.oidcCallbackHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/users", app.requirePermission(dto.UsersRead, app.listUsersHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/users/:id/permissions", app.requirePermission(dto.PermissionsRead, app.getUserPermissionsHandler))
 	router.HandlerFunc(http.MethodPut, "/v1/users/:id/permissions", app.requirePermission(dto.PermissionsWrite, app.addUserPermissionsHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/permissions", app.requirePermission(dto.PermissionsWrite, app.deleteUserPermissionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/roles", app.requirePermission(dto.PermissionsRead, app.getUserRolesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/roles", app.requirePermission(dto.PermissionsWrite, app.addUserRolesHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/roles", app.requirePermission(dto.PermissionsWrite, app.deleteUserRolesHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/api-keys", app.requireActivatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/api-keys", app.requireActivatedUser(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/api-keys/:key_id", app.requireActivatedUser(app.deleteAPIKeyHandler))
+
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/lockout", app.requirePermission(dto.UsersWrite, app.unlockUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/totp", app.requireActivatedUser(app.enrollTOTPHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/totp/confirmed", app.requireActivatedUser(app.confirmTOTPHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/totp", app.requireActivatedUser(app.disableTOTPHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/roles", app.requirePermission(dto.PermissionsRead, app.listRolesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/roles", app.requirePermission(dto.PermissionsWrite, app.createRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/roles/:id", app.requirePermission(dto.PermissionsRead, app.showRoleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/roles/:id", app.requirePermission(dto.PermissionsWrite, app.updateRoleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/roles/:id", app.requirePermission(dto.PermissionsWrite, app.deleteRoleHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/batch", app.batchHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/audit", app.requirePermission(dto.AuditRead, app.listAuditEventsHandler))
+
+	// Batches dispatch their sub-requests through the same handler.
+	app.routes = app.recoverPanic(app.requestID(app.enableCORS(app.authenticate(router))))
+	return app.routes
+}
 
-	return app.recoverPanic(app.enableCORS(app.authenticate(router)))
+// httprouter doesn't allow a static path segment in the same position as a named
+// parameter, so a route like /v1/movies/autocomplete is registered as /v1/movies/:id
+// and picked out here by the value of the parameter. Any other value is passed on to
+// next, or gets a 404 Not Found response if next is nil.
+func (app *Application) static(routes map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := routes[params.ByName("id")]; ok {
+			handler(w, r)
+			return
+		}
+		if next == nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
 }
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"net/http"
+)
+
+// The listSessionsHandler() returns the sessions of the user making the request,
+// marking the one the request was made with as current.
+func (app *Application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to manage sessions")
+		return
+	}
+	sessions, err := app.Models.Tokens.GetAllSessionsForUser(helpers.ContextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if current := helpers.ContextGetSession(r); current != nil {
+		for _, session := range sessions {
+			session.Current = session.ID == current.ID
+		}
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The deleteSessionHandler() logs out one of the sessions of the user making the
+// request, which may be the current one.
+func (app *Application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to manage sessions")
+		return
+	}
+	id, err := helpers.ReadInt64Param(r, "session_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.Models.Tokens.DeleteSession(id, helpers.ContextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "session successfully logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -29,11 +29,24 @@ func (app *Application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	// Refuse the attempt if there have been too many failed logins for the email
+	// address or from the client IP. This is checked before looking up the user, so
+	// that unknown addresses are throttled in the same way.
+	retryAfter, err := app.loginRetryAfter(r, input.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.loginThrottledResponse(w, r, retryAfter)
+		return
+	}
 	user, err := app.Models.Users.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, postgresql.ErrRecordNotFound):
-			app.invalidCredentialsResponse(w, r)
+			dto.SimulatePasswordCheck(input.Password)
+			app.failedLoginResponse(w, r, input.Email, nil)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -46,12 +59,27 @@ func (app *Application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 	if !match {
-		app.invalidCredentialsResponse(w, r)
+		app.failedLoginResponse(w, r, input.Email, user)
+		return
+	}
+	err = app.Models.Logins.Reset(dto.LoginFailureScopeEmail, dto.LoginFailureEmailKey(input.Email))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Upgrade the hash if it was made with another algorithm or outdated parameters,
+	// while the plaintext password is at hand. The login goes ahead if this fails.
+	if user.Password.NeedsRehash() {
+		app.rehashPassword(user, input.Password)
+	}
+	// Users with two-factor authentication enabled get a short-lived 2fa-pending token
+	// instead.
+	if app.twoFactorPendingResponse(w, r, user) {
 		return
 	}
 	// Otherwise, if the password is correct, we generate a new token with a 24-hour
 	// expiry time and the scope 'authentication'.
-	token, err := app.Models.Tokens.New(user.ID, 24*time.Hour, dto.ScopeAuthentication)
+	token, err := app.Models.Tokens.NewSession(user.ID, 24*time.Hour, helpers.ClientIP(r), helpers.UserAgent(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -63,3 +91,27 @@ func (app *Application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// The failedLoginResponse() helper records a failed login and sends the invalid
+// credentials response.
+func (app *Application) failedLoginResponse(w http.ResponseWriter, r *http.Request, email string, user *dto.User) {
+	err := app.recordLoginFailure(r, email, user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.invalidCredentialsResponse(w, r)
+}
+
+// The rehashPassword() helper replaces the user's password hash with one made by the
+// default hasher. Errors are logged, and an edit conflict means that the user has
+// been changed since it was read, so the hash is left to the next login.
+func (app *Application) rehashPassword(user *dto.User, plaintextPassword string) {
+	err := user.Password.Set(plaintextPassword)
+	if err == nil {
+		err = app.Models.Users.Update(user)
+	}
+	if err != nil && !errors.Is(err, postgresql.ErrEditConflict) {
+		app.logError(err)
+	}
+}
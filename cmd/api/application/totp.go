@@ -0,0 +1,316 @@
+package application
+
+import (
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/totp"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"time"
+)
+
+// How long a user has to enter their second factor after their password.
+const twoFactorPendingTTL = 5 * time.Minute
+
+// The enrollTOTPHandler() generates a new TOTP secret for the user. It isn't used for
+// logging in until it is confirmed with a code by confirmTOTPHandler().
+func (app *Application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readTOTPUser(w, r)
+	if !ok {
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	t := &dto.TOTP{UserID: user.ID, Secret: secret}
+	err = app.Models.TOTP.Enroll(t)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrTOTPEnabled):
+			app.twoFactorEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	env := helpers.Envelope{"totp": t, "otpauth_uri": totp.URI(t.Secret, app.Config.TOTP.Issuer, user.Email)}
+	err = helpers.WriteJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The confirmTOTPHandler() enables two-factor authentication once the user has shown
+// that their authenticator app produces the right codes, and returns their recovery
+// codes. This is the only time the recovery codes are sent.
+func (app *Application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readTOTPUser(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Code string `json:"code"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if dto.ValidateTOTPCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	t, err := app.Models.TOTP.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if t.Enabled() {
+		app.twoFactorEnabledResponse(w, r)
+		return
+	}
+	step, ok, err := t.Check(input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		v.AddError("code", "invalid or expired code")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	codes, hashes, err := dto.GenerateRecoveryCodes()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.TOTP.Confirm(t, step, hashes)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditTOTPEnable, dto.AuditTargetUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrTOTPEnabled):
+			app.twoFactorEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"totp": t, "recovery_codes": codes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The disableTOTPHandler() turns off two-factor authentication. Users turning it off
+// for themselves must provide a code or a recovery code, while users holding
+// users:write can reset it for other users who have lost their authenticator.
+func (app *Application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to manage two-factor authentication")
+		return
+	}
+	if id == helpers.ContextGetUser(r).ID {
+		var input struct {
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		err = helpers.ReadJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		v := validator.New()
+		if dto.ValidateSecondFactor(v, input.Code, input.RecoveryCode); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		ok, err := app.checkSecondFactor(id, input.Code, input.RecoveryCode)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !ok {
+			v.AddError("code", "invalid or expired code")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	} else {
+		permissions, err := app.userPermissions(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Include(dto.UsersWrite) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.TOTP.Delete(id)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditTOTPDisable, dto.AuditTargetUser, id, nil, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "two-factor authentication successfully disabled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The createTwoFactorTokenHandler() exchanges the 2fa-pending token from
+// createAuthenticationTokenHandler() and a second factor for an authentication token.
+func (app *Application) createTwoFactorTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	dto.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	dto.ValidateSecondFactor(v, input.Code, input.RecoveryCode)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	user, err := app.Models.Users.GetForToken(dto.ScopeTwoFactorPending, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Each pending token is good for a single attempt, so guessing codes means entering
+	// the password again every time.
+	err = app.Models.Tokens.DeleteAllForUser(dto.ScopeTwoFactorPending, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	ok, err := app.checkSecondFactor(user.ID, input.Code, input.RecoveryCode)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		app.failedLoginResponse(w, r, user.Email, user)
+		return
+	}
+	token, err := app.Models.Tokens.NewSession(user.ID, 24*time.Hour, helpers.ClientIP(r), helpers.UserAgent(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"authentication_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The checkSecondFactor() helper checks a TOTP code or a recovery code for a user
+// with two-factor authentication enabled, using it up if it is valid.
+func (app *Application) checkSecondFactor(userID int64, code, recoveryCode string) (bool, error) {
+	t, err := app.Models.TOTP.Get(userID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !t.Enabled() {
+		return false, nil
+	}
+	if recoveryCode != "" {
+		return app.Models.TOTP.UseRecoveryCode(userID, recoveryCode)
+	}
+	step, ok, err := t.Check(code)
+	if err != nil || !ok {
+		return false, err
+	}
+	return app.Models.TOTP.UseStep(userID, step)
+}
+
+// The readTOTPUser() helper returns the user whose TOTP secret is being set up, who
+// must be the user making the request. API keys can't be used to set up two-factor
+// authentication. It sends an error response and returns false if the request isn't
+// allowed.
+func (app *Application) readTOTPUser(w http.ResponseWriter, r *http.Request) (*dto.User, bool) {
+	id, err := helpers.ReadIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to manage two-factor authentication")
+		return nil, false
+	}
+	user := helpers.ContextGetUser(r)
+	if id != user.ID {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+	return user, true
+}
+
+// The twoFactorPendingResponse() helper sends a short-lived 2fa-pending token, which
+// createTwoFactorTokenHandler() exchanges for an authentication token along with a
+// code, if the user has two-factor authentication enabled. It reports whether a
+// response was sent, in which case the login must not go on.
+func (app *Application) twoFactorPendingResponse(w http.ResponseWriter, r *http.Request, user *dto.User) bool {
+	t, err := app.Models.TOTP.Get(user.ID)
+	if err != nil && !errors.Is(err, postgresql.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return true
+	}
+	if err != nil || !t.Enabled() {
+		return false
+	}
+	token, err := app.Models.Tokens.New(user.ID, twoFactorPendingTTL, dto.ScopeTwoFactorPending)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return true
+	}
+	err = helpers.WriteJSON(w, http.StatusAccepted, helpers.Envelope{"two_factor_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+	return true
+}
@@ -3,6 +3,7 @@ package application
 import (
 	"errors"
 	"github.com/kientink26/go-json-api/cmd/api/helpers"
+	"github.com/kientink26/go-json-api/internal/data"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
 	"github.com/kientink26/go-json-api/internal/validator"
@@ -72,7 +73,13 @@ func (app *Application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	v := validator.New()
-	if dto.ValidateUser(v, user); !v.Valid() {
+	dto.ValidateUser(v, user)
+	err = app.checkBreachedPassword(v, input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -182,3 +189,223 @@ func (app *Application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// The showCurrentUserHandler() returns the user making the request, along with their
+// permissions, limited to those of the API key when one is used.
+func (app *Application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := helpers.ContextGetUser(r)
+	permissions, err := app.userPermissions(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"user": user, "permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The updateCurrentUserHandler() changes the name or password of the user making the
+// request. Changing the password needs the current one, logs out every session of the
+// user and revokes their API keys, so a new authentication token for this one is sent
+// in the response.
+// If the version is given, it must match the user's current version.
+func (app *Application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to change the account")
+		return
+	}
+	var input struct {
+		Name            *string `json:"name"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Version         *int    `json:"version"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	if input.Version != nil && *input.Version != user.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+	before := *user
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	v := validator.New()
+	if input.Password != nil {
+		v.Check(input.CurrentPassword != "", "current_password", "must be provided")
+		err = user.Password.Set(*input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	dto.ValidateUser(v, user)
+	if input.Password != nil {
+		err = app.checkBreachedPassword(v, *input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// The current password is only checked once the rest is valid, so that mistakes
+	// elsewhere don't count as failed logins.
+	if input.Password != nil && !app.checkCurrentPassword(w, r, v, "current_password", &before, input.CurrentPassword) {
+		return
+	}
+	var token *dto.Token
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Users.Update(user)
+		if err != nil {
+			return err
+		}
+		if input.Password != nil {
+			for _, scope := range []string{dto.ScopeAuthentication, dto.ScopeTwoFactorPending} {
+				err = m.Tokens.DeleteAllForUser(scope, user.ID)
+				if err != nil {
+					return err
+				}
+			}
+			err = m.APIKeys.DeleteAllForUser(user.ID)
+			if err != nil {
+				return err
+			}
+			token, err = m.Tokens.NewSession(user.ID, 24*time.Hour, helpers.ClientIP(r), helpers.UserAgent(r))
+			if err != nil {
+				return err
+			}
+		}
+		return app.audit(m, r, dto.AuditUserUpdate, dto.AuditTargetUser, user.ID, &before, user)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	env := helpers.Envelope{"user": user}
+	if token != nil {
+		env["authentication_token"] = token
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The deleteCurrentUserHandler() schedules the account of the user making the
+// request to be deleted, once they have confirmed it with their password. Until the
+// grace period is over they can cancel it with cancelUserDeletionHandler(), after
+// which the account is anonymized and their comments are kept.
+func (app *Application) deleteCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to delete the account")
+		return
+	}
+	var input struct {
+		Password string `json:"password"`
+	}
+	err := helpers.ReadJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	v := validator.New()
+	if v.Check(input.Password != "", "password", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !app.checkCurrentPassword(w, r, v, "password", user, input.Password) {
+		return
+	}
+	scheduledFor := time.Now().Add(app.Config.Deletion.GracePeriod).Truncate(time.Second)
+	err = app.Models.Transaction(func(m data.Models) error {
+		err := m.Users.ScheduleDeletion(user.ID, scheduledFor)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditUserDelete, dto.AuditTargetUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.background(func() {
+		err := app.Mailer.Send(user.Email, "account_deletion_scheduled.gohtml", map[string]interface{}{
+			"scheduledFor": scheduledFor.UTC().Format(time.RFC1123),
+		})
+		if err != nil {
+			app.logError(err)
+		}
+	})
+	env := helpers.Envelope{
+		"message":                "account scheduled for deletion",
+		"deletion_scheduled_for": scheduledFor,
+	}
+	err = helpers.WriteJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The cancelUserDeletionHandler() stops the account of the user making the request
+// from being deleted, if the grace period isn't over yet.
+func (app *Application) cancelUserDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	if helpers.ContextGetAPIKey(r) != nil {
+		app.forbiddenResponse(w, r, "API keys cannot be used to delete the account")
+		return
+	}
+	user := helpers.ContextGetUser(r)
+	err := app.Models.Transaction(func(m data.Models) error {
+		err := m.Users.CancelDeletion(user.ID)
+		if err != nil {
+			return err
+		}
+		return app.audit(m, r, dto.AuditUserDeletionCancel, dto.AuditTargetUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgresql.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"message": "account deletion successfully cancelled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The checkBreachedPassword() helper adds a validation error if a new password is in
+// the breached password corpus. Passwords which already failed validation aren't
+// checked.
+func (app *Application) checkBreachedPassword(v *validator.Validator, plaintextPassword string) error {
+	if _, exists := v.Errors["password"]; exists || app.Breached == nil {
+		return nil
+	}
+	breached, err := app.Breached.Contains(plaintextPassword)
+	if err != nil {
+		return err
+	}
+	v.Check(!breached, "password", "has appeared in a data breach, please choose another")
+	return nil
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Config struct {
 	Port int
 	Env  string
@@ -16,4 +18,58 @@ type Config struct {
 	Cors struct {
 		TrustedOrigin string
 	}
+	Search struct {
+		Language string
+	}
+	Trash struct {
+		Retention time.Duration
+	}
+	Idempotency struct {
+		TTL time.Duration
+	}
+	// How long a user has to cancel deleting their account before it is anonymized.
+	Deletion struct {
+		GracePeriod time.Duration
+	}
+	// How long a user's data export can be downloaded for.
+	Export struct {
+		TTL time.Duration
+	}
+	// Logging in with OpenID Connect is enabled when an issuer is configured.
+	OIDC struct {
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		RedirectURL  string
+	}
+	// Failed logins are counted per email address and per client IP, which has a
+	// higher threshold as many users may share an address.
+	Lockout struct {
+		Threshold   int
+		IPThreshold int
+		Window      time.Duration
+		Duration    time.Duration
+	}
+	// Hasher is the algorithm used for new password hashes, either argon2id or
+	// bcrypt. Argon2Memory is in KiB.
+	Password struct {
+		Hasher            string
+		Argon2Memory      uint
+		Argon2Iterations  uint
+		Argon2Parallelism uint
+		BcryptCost        int
+		// BreachedDir holds the breached password corpus. Passwords aren't checked
+		// against it if it is empty.
+		BreachedDir string
+	}
+	// The issuer is the account name shown by authenticator apps.
+	TOTP struct {
+		Issuer string
+	}
+	// SuperCodes are the permission codes which only the bootstrap admin may grant or
+	// revoke. A BootstrapAdmin of 0 means that nobody can.
+	Permissions struct {
+		SuperCodes     []string
+		BootstrapAdmin int64
+	}
 }
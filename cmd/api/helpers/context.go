@@ -13,6 +13,15 @@ type contextKey string
 // in the request context.
 const userContextKey = contextKey("user")
 
+// The key for the ID which identifies the request in logs and the audit trail.
+const requestIDContextKey = contextKey("requestID")
+
+// The key for the API key which authenticated the request, if any.
+const apiKeyContextKey = contextKey("apiKey")
+
+// The key for the session whose authentication token authenticated the request, if any.
+const sessionContextKey = contextKey("session")
+
 // The ContextSetUser() method returns a new copy of the request with the provided
 // User struct added to the context.
 func ContextSetUser(r *http.Request, user *dto.User) *http.Request {
@@ -28,3 +37,45 @@ func ContextGetUser(r *http.Request) *dto.User {
 	}
 	return user
 }
+
+// The ContextSetRequestID() method returns a new copy of the request with the provided
+// request ID added to the context.
+func ContextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// The ContextGetRequestID() retrieves the request ID from the request context, or the
+// empty string if there isn't one.
+func ContextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// The ContextSetAPIKey() method returns a new copy of the request with the API key
+// used to authenticate it added to the context.
+func ContextSetAPIKey(r *http.Request, key *dto.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// The ContextGetAPIKey() retrieves the API key from the request context, or nil if
+// the request wasn't authenticated with one.
+func ContextGetAPIKey(r *http.Request) *dto.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*dto.APIKey)
+	return key
+}
+
+// The ContextSetSession() method returns a new copy of the request with the session
+// whose authentication token was used to authenticate it added to the context.
+func ContextSetSession(r *http.Request, session *dto.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContextKey, session)
+	return r.WithContext(ctx)
+}
+
+// The ContextGetSession() retrieves the session from the request context, or nil if
+// the request wasn't authenticated with an authentication token.
+func ContextGetSession(r *http.Request) *dto.Session {
+	session, _ := r.Context().Value(sessionContextKey).(*dto.Session)
+	return session
+}
@@ -7,22 +7,52 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/kientink26/go-json-api/internal/validator"
 	"io"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Envelope map[string]interface{}
 
 func ReadIDParam(r *http.Request) (int64, error) {
+	return ReadInt64Param(r, "id")
+}
+
+// ReadInt64Param reads a positive integer from the named URL parameter.
+func ReadInt64Param(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
-	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+	i, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
-	return id, nil
+	return i, nil
+}
+
+// ClientIP returns the IP address of the client which sent the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// The longest user agent kept for a session.
+const maxUserAgentLength = 512
+
+// UserAgent returns the User-Agent header of the request, cut short if it is too long
+// to be worth keeping.
+func UserAgent(r *http.Request) string {
+	ua := strings.ToValidUTF8(r.UserAgent(), "")
+	if len(ua) > maxUserAgentLength {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLength], "")
+	}
+	return ua
 }
 
 func WriteJSON(w http.ResponseWriter, status int, data Envelope, headers http.Header) error {
@@ -98,6 +128,20 @@ func ReadJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	return nil
 }
 
+// ReadMediaType returns the media type of the request body, without any parameters.
+// A request without a Content-Type header is treated as JSON.
+func ReadMediaType(r *http.Request) (string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "application/json", nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", errors.New("invalid Content-Type header")
+	}
+	return mediaType, nil
+}
+
 func ReadString(qs url.Values, key string, defaultValue string) string {
 	s := qs.Get(key)
 	if s == "" {
@@ -127,6 +171,19 @@ func ReadInt(qs url.Values, key string, defaultValue int, v *validator.Validator
 	return i
 }
 
+func ReadInt64(qs url.Values, key string, defaultValue int64, v *validator.Validator) int64 {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
 func ReadEnv() (map[string]interface{}, error) {
 	envMap := make(map[string]interface{})
 	var err error
@@ -147,3 +204,22 @@ func ReadEnv() (map[string]interface{}, error) {
 	envMap["CORS_ORIGIN"] = os.Getenv("CORS_ORIGIN")
 	return envMap, nil
 }
+
+// ReadTime reads an RFC 3339 timestamp, or a plain 2006-01-02 date, from the query
+// string. The zero time is returned if the key isn't present.
+func ReadTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t
+	}
+	t, err = time.Parse("2006-01-02", s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		return time.Time{}
+	}
+	return t
+}
@@ -3,16 +3,24 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/kientink26/go-json-api/cmd/api/application"
 	"github.com/kientink26/go-json-api/cmd/api/config"
 	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/mailer"
+	"github.com/kientink26/go-json-api/internal/oidc"
+	"github.com/kientink26/go-json-api/internal/password"
+	"github.com/kientink26/go-json-api/internal/validator"
 	_ "github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -32,8 +40,69 @@ func main() {
 	flag.StringVar(&cfg.Smtp.Password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.Smtp.Sender, "smtp-sender", "", "SMTP sender")
 	flag.StringVar(&cfg.Cors.TrustedOrigin, "cors-trusted-origin", "", "Trusted CORS origin")
+	flag.DurationVar(&cfg.Trash.Retention, "trash-retention", 30*24*time.Hour, "How long deleted movies are kept in the trash")
+	flag.DurationVar(&cfg.Idempotency.TTL, "idempotency-ttl", 24*time.Hour, "How long responses to requests with an Idempotency-Key are kept")
+	flag.StringVar(&cfg.Search.Language, "search-language", "simple", "PostgreSQL text search configuration for movie titles")
+	cfg.Permissions.SuperCodes = []string{dto.PermissionsWrite}
+	flag.Func("super-permissions", "Comma-separated permission codes which only the bootstrap admin can grant (default \"permissions:write\")", func(s string) error {
+		// An empty value keeps the default, rather than leaving every permission
+		// grantable by anyone with permissions:write.
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		cfg.Permissions.SuperCodes = nil
+		for _, code := range strings.Split(s, ",") {
+			code = strings.TrimSpace(code)
+			if code == "" {
+				continue
+			}
+			if !validator.In(code, dto.PermissionList...) {
+				return fmt.Errorf("unknown permission code %q", code)
+			}
+			cfg.Permissions.SuperCodes = append(cfg.Permissions.SuperCodes, code)
+		}
+		if len(cfg.Permissions.SuperCodes) == 0 {
+			return errors.New("must list at least one permission code")
+		}
+		return nil
+	})
+	flag.Int64Var(&cfg.Permissions.BootstrapAdmin, "bootstrap-admin", 0, "ID of the user allowed to grant the super permissions")
+	flag.StringVar(&cfg.OIDC.Issuer, "oidc-issuer", "", "OpenID Connect issuer URL (login with OIDC is disabled if empty)")
+	flag.StringVar(&cfg.OIDC.ClientID, "oidc-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&cfg.OIDC.ClientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	flag.StringVar(&cfg.OIDC.RedirectURL, "oidc-redirect-url", "", "OpenID Connect redirect URL")
+	flag.StringVar(&cfg.TOTP.Issuer, "totp-issuer", "Greenlight", "Issuer shown in authenticator apps for two-factor authentication")
+	flag.IntVar(&cfg.Lockout.Threshold, "lockout-threshold", 10, "Failed logins for an email address before it is locked")
+	flag.IntVar(&cfg.Lockout.IPThreshold, "lockout-ip-threshold", 100, "Failed logins from a client IP before it is locked")
+	flag.DurationVar(&cfg.Lockout.Window, "lockout-window", time.Hour, "How long failed logins are counted for")
+	flag.DurationVar(&cfg.Lockout.Duration, "lockout-duration", 15*time.Minute, "How long logins are locked for")
+	flag.StringVar(&cfg.Password.Hasher, "password-hasher", "argon2id", "Algorithm for new password hashes (argon2id|bcrypt)")
+	flag.UintVar(&cfg.Password.Argon2Memory, "argon2-memory", uint(password.DefaultArgon2id.Memory), "Memory used by argon2id in KiB")
+	flag.UintVar(&cfg.Password.Argon2Iterations, "argon2-iterations", uint(password.DefaultArgon2id.Iterations), "Number of argon2id iterations")
+	flag.UintVar(&cfg.Password.Argon2Parallelism, "argon2-parallelism", uint(password.DefaultArgon2id.Parallelism), "Number of argon2id threads")
+	flag.IntVar(&cfg.Password.BcryptCost, "bcrypt-cost", 12, "Cost of bcrypt password hashes")
+	flag.StringVar(&cfg.Password.BreachedDir, "breached-passwords-dir", "", "Directory of the breached password corpus, in the Have I Been Pwned range format")
+	flag.DurationVar(&cfg.Export.TTL, "export-ttl", 72*time.Hour, "How long data exports can be downloaded for")
+	flag.DurationVar(&cfg.Deletion.GracePeriod, "deletion-grace-period", 14*24*time.Hour, "How long users can cancel deleting their account for")
 	flag.Parse()
 
+	// A retention of zero or less would purge movies from the trash as soon as they
+	// are deleted, so that they could never be restored.
+	if cfg.Trash.Retention <= 0 {
+		logger.Fatal("trash-retention must be greater than zero")
+	}
+	// Likewise, a grace period of zero or less would leave no time to cancel deleting
+	// an account.
+	if cfg.Deletion.GracePeriod <= 0 {
+		logger.Fatal("deletion-grace-period must be greater than zero")
+	}
+
+	hasher, err := passwordHasher(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	password.Default = hasher
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Fatal(err)
@@ -43,12 +112,28 @@ func main() {
 	defer db.Close()
 	logger.Printf("database connection pool established")
 
+	models := data.NewModels(db, cfg.Search.Language)
+	// Re-index the movies which were indexed with another text search configuration.
+	reindexed, err := models.Movies.Reindex()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if reindexed > 0 {
+		logger.Printf("re-indexed %d movies for the %q search language", reindexed, cfg.Search.Language)
+	}
+
 	app := &application.Application{
 		Config: cfg,
 		Logger: logger,
-		Models: data.NewModels(db),
+		Models: models,
 		Mailer: mailer.New(cfg.Smtp.Host, cfg.Smtp.Port, cfg.Smtp.Username, cfg.Smtp.Password, cfg.Smtp.Sender),
 	}
+	if cfg.Password.BreachedDir != "" {
+		app.Breached = &password.Corpus{Dir: cfg.Password.BreachedDir}
+	}
+	if cfg.OIDC.Issuer != "" {
+		app.OIDC = oidc.New(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDC.RedirectURL)
+	}
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      app.Routes(),
@@ -56,6 +141,7 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	app.StartJobs()
 	logger.Printf("starting %s server on %s", cfg.Env, srv.Addr)
 	err = srv.ListenAndServe()
 	if err != nil {
@@ -83,3 +169,35 @@ func openDB(cfg config.Config) (*sql.DB, error) {
 	// Return the sql.DB connection pool.
 	return db, nil
 }
+
+// The passwordHasher() function returns the configured hasher for new passwords.
+func passwordHasher(cfg config.Config) (password.Hasher, error) {
+	switch cfg.Password.Hasher {
+	case "argon2id":
+		// argon2 panics without at least one iteration and thread, and the values are
+		// narrowed below, so out of range values are refused rather than truncated.
+		p := cfg.Password
+		switch {
+		case p.Argon2Iterations < 1 || p.Argon2Iterations > math.MaxUint32:
+			return nil, fmt.Errorf("argon2-iterations must be between 1 and %d", uint32(math.MaxUint32))
+		case p.Argon2Parallelism < 1 || p.Argon2Parallelism > math.MaxUint8:
+			return nil, fmt.Errorf("argon2-parallelism must be between 1 and %d", math.MaxUint8)
+		case p.Argon2Memory < 8*p.Argon2Parallelism || p.Argon2Memory > math.MaxUint32:
+			return nil, fmt.Errorf("argon2-memory must be between 8 KiB per thread and %d KiB", uint32(math.MaxUint32))
+		}
+		return password.Argon2id{
+			Memory:      uint32(cfg.Password.Argon2Memory),
+			Iterations:  uint32(cfg.Password.Argon2Iterations),
+			Parallelism: uint8(cfg.Password.Argon2Parallelism),
+			SaltLength:  password.DefaultArgon2id.SaltLength,
+			KeyLength:   password.DefaultArgon2id.KeyLength,
+		}, nil
+	case "bcrypt":
+		if cfg.Password.BcryptCost < bcrypt.MinCost || cfg.Password.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return password.Bcrypt{Cost: cfg.Password.BcryptCost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", cfg.Password.Hasher)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/kientink26/go-json-api/cmd/api/application"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"github.com/kientink26/go-json-api/internal/oidc"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testIdP is an in-process stand-in identity provider, which hands out an ID token
+// with the configured claims for the authorization code "test-code".
+type testIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "test-code" || oidc.Challenge(r.PostForm.Get("code_verifier")) != oidc.Challenge("test-verifier") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) sign(t *testing.T) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, err := json.Marshal(idp.claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// insertTestUser adds a user with the given email address and password.
+func insertTestUser(t *testing.T, app *application.Application, email, password string, activated bool) *dto.User {
+	user := &dto.User{Name: "Alice", Email: email, Activated: activated}
+	if err := user.Password.Set(password); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// sessionUser returns the ID of the user who was issued the authentication token in
+// a response body.
+func sessionUser(t *testing.T, app *application.Application, body []byte) int64 {
+	var output struct {
+		Token struct {
+			Plaintext string `json:"token"`
+		} `json:"authentication_token"`
+	}
+	if err := json.Unmarshal(body, &output); err != nil {
+		t.Fatal(err)
+	}
+	user, err := app.Models.Users.GetForToken(dto.ScopeAuthentication, output.Token.Plaintext)
+	if err != nil {
+		t.Fatalf("want a valid authentication token; got %v", err)
+	}
+	return user.ID
+}
+
+func TestOIDCCallback(t *testing.T) {
+	idp := newTestIdP(t)
+	callback := []byte(`{"code": "test-code", "state": "test-state"}`)
+	tests := []struct {
+		name          string
+		emailVerified bool
+		setup         func(t *testing.T, app *application.Application) int64
+		wantCode      int
+		wantBody      []byte
+		check         func(t *testing.T, app *application.Application, userID int64, body []byte)
+	}{
+		{
+			name:          "Existing link",
+			emailVerified: false,
+			setup: func(t *testing.T, app *application.Application) int64 {
+				user := insertTestUser(t, app, "alice.old@example.com", "pa55word1234", true)
+				if err := app.Models.OIDC.LinkIdentity(idp.URL, "user-123", user.ID); err != nil {
+					t.Fatal(err)
+				}
+				return user.ID
+			},
+			wantCode: http.StatusCreated,
+			check: func(t *testing.T, app *application.Application, userID int64, body []byte) {
+				if got := sessionUser(t, app, body); got != userID {
+					t.Errorf("want session for user %d; got %d", userID, got)
+				}
+			},
+		},
+		{
+			name:          "Link by verified email",
+			emailVerified: true,
+			setup: func(t *testing.T, app *application.Application) int64 {
+				return insertTestUser(t, app, "alice@example.com", "pa55word1234", true).ID
+			},
+			wantCode: http.StatusCreated,
+			check: func(t *testing.T, app *application.Application, userID int64, body []byte) {
+				if got := sessionUser(t, app, body); got != userID {
+					t.Errorf("want session for user %d; got %d", userID, got)
+				}
+				user, err := app.Models.OIDC.GetUserForIdentity(idp.URL, "user-123")
+				if err != nil || user.ID != userID {
+					t.Errorf("want identity linked to user %d; got %v, %v", userID, user, err)
+				}
+				// The user keeps their password.
+				if ok, _ := user.Password.Matches("pa55word1234"); !ok {
+					t.Error("want password unchanged")
+				}
+			},
+		},
+		{
+			name:          "Auto-create",
+			emailVerified: true,
+			wantCode:      http.StatusCreated,
+			check: func(t *testing.T, app *application.Application, userID int64, body []byte) {
+				user, err := app.Models.Users.GetByEmail("alice@example.com")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !user.Activated || user.Name != "Alice" {
+					t.Errorf("want activated user named Alice; got %+v", user)
+				}
+				if got := sessionUser(t, app, body); got != user.ID {
+					t.Errorf("want session for user %d; got %d", user.ID, got)
+				}
+				permissions, err := app.Models.Permissions.GetAllForUser(user.ID)
+				if err != nil || !permissions.Include(dto.CommentsWrite) {
+					t.Errorf("want %s permission; got %v, %v", dto.CommentsWrite, permissions, err)
+				}
+			},
+		},
+		{
+			name:          "Unverified email",
+			emailVerified: false,
+			setup: func(t *testing.T, app *application.Application) int64 {
+				return insertTestUser(t, app, "alice@example.com", "pa55word1234", true).ID
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: []byte("has not verified your email address"),
+			check: func(t *testing.T, app *application.Application, userID int64, body []byte) {
+				_, err := app.Models.OIDC.GetUserForIdentity(idp.URL, "user-123")
+				if !errors.Is(err, postgresql.ErrRecordNotFound) {
+					t.Errorf("want identity not linked; got %v", err)
+				}
+			},
+		},
+		{
+			name:          "Unactivated account",
+			emailVerified: true,
+			setup: func(t *testing.T, app *application.Application) int64 {
+				// Someone else registered with the address, without confirming it.
+				user := insertTestUser(t, app, "alice@example.com", "attacker-pa55word", false)
+				if _, err := app.Models.Tokens.NewSession(user.ID, time.Hour, "192.0.2.1", "attacker"); err != nil {
+					t.Fatal(err)
+				}
+				key, err := dto.GenerateAPIKey(user.ID, "attacker", dto.Permissions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := app.Models.APIKeys.Insert(key); err != nil {
+					t.Fatal(err)
+				}
+				now := time.Now()
+				if err := app.Models.TOTP.Enroll(&dto.TOTP{UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+					t.Fatal(err)
+				}
+				if err := app.Models.TOTP.Confirm(&dto.TOTP{UserID: user.ID, ConfirmedAt: &now}, 1, nil); err != nil {
+					t.Fatal(err)
+				}
+				return user.ID
+			},
+			wantCode: http.StatusCreated,
+			check: func(t *testing.T, app *application.Application, userID int64, body []byte) {
+				if got := sessionUser(t, app, body); got != userID {
+					t.Errorf("want session for user %d; got %d", userID, got)
+				}
+				user, err := app.Models.Users.Get(userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !user.Activated {
+					t.Error("want user activated")
+				}
+				if ok, _ := user.Password.Matches("attacker-pa55word"); ok {
+					t.Error("want password replaced")
+				}
+				tokens, err := app.Models.Tokens.GetAllForUser(userID)
+				if err != nil || len(tokens) != 1 {
+					t.Errorf("want only the new session; got %d tokens, %v", len(tokens), err)
+				}
+				keys, err := app.Models.APIKeys.GetAllForUser(userID)
+				if err != nil || len(keys) != 0 {
+					t.Errorf("want API keys revoked; got %d keys, %v", len(keys), err)
+				}
+				if _, err := app.Models.TOTP.Get(userID); !errors.Is(err, postgresql.ErrRecordNotFound) {
+					t.Errorf("want two-factor authentication removed; got %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.OIDC = oidc.New(idp.URL, "greenlight", "secret", "https://app.example.com/callback")
+			idp.claims = map[string]interface{}{
+				"iss":            idp.URL,
+				"sub":            "user-123",
+				"aud":            "greenlight",
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"iat":            time.Now().Unix(),
+				"nonce":          "test-nonce",
+				"email":          "alice@example.com",
+				"email_verified": tt.emailVerified,
+				"name":           "Alice",
+			}
+			var userID int64
+			if tt.setup != nil {
+				userID = tt.setup(t, app)
+			}
+			login := &dto.OIDCLogin{State: "test-state", Nonce: "test-nonce", Verifier: "test-verifier", Expiry: time.Now().Add(time.Minute)}
+			if err := app.Models.OIDC.InsertLogin(login); err != nil {
+				t.Fatal(err)
+			}
+			ts := newTestServer(t, app.Routes())
+			defer ts.Close()
+			code, _, body := ts.post(t, "/v1/tokens/oidc/callback", callback)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d: %s", tt.wantCode, code, body)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			tt.check(t, app, userID, body)
+			// Each login can only be completed once.
+			code, _, body = ts.post(t, "/v1/tokens/oidc/callback", callback)
+			if code != http.StatusUnprocessableEntity || !bytes.Contains(body, []byte("invalid or expired login state")) {
+				t.Errorf("want reused state rejected; got %d: %s", code, body)
+			}
+		})
+	}
+}
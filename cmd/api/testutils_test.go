@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/kientink26/go-json-api/cmd/api/application"
 	"github.com/kientink26/go-json-api/internal/data"
+	"github.com/kientink26/go-json-api/internal/data/dto"
 	"io"
 	"log"
 	"net/http"
@@ -47,6 +49,21 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 	return rs.StatusCode, rs.Header, body
 }
 
+// Implement a post method on our custom testServer type, which sends the body as
+// JSON.
+func (ts *testServer) post(t *testing.T, urlPath string, body []byte) (int, http.Header, []byte) {
+	rs, err := ts.Client().Post(ts.URL+urlPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, respBody
+}
+
 func TestShowMovie(t *testing.T) {
 	// Create a new instance of our application struct which uses the mocked
 	// dependencies.
@@ -80,3 +97,70 @@ func TestShowMovie(t *testing.T) {
 		})
 	}
 }
+
+func TestAutocompleteMovies(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Matching prefix", "/v1/movies/autocomplete?prefix=black", http.StatusOK, []byte("Black Panther")},
+		{"No match", "/v1/movies/autocomplete?prefix=casablanca", http.StatusOK, []byte(`"movies": []`)},
+		{"Missing prefix", "/v1/movies/autocomplete", http.StatusUnprocessableEntity, []byte("prefix")},
+		{"Limit too large", "/v1/movies/autocomplete?prefix=b&limit=500", http.StatusUnprocessableEntity, []byte("limit")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+	tests := []struct {
+		name          string
+		body          string
+		wantCode      int
+		wantResponses int
+		wantBody      []byte
+	}{
+		{"Independent requests", `{"requests": [{"method": "GET", "path": "/v1/movies/2"}, {"method": "GET", "path": "/v1/movies/1"}]}`, http.StatusOK, 2, []byte("Black Panther")},
+		{"Atomic success", `{"atomic": true, "requests": [{"method": "GET", "path": "/v1/movies/1"}]}`, http.StatusOK, 1, []byte(`"committed": true`)},
+		{"Atomic failure", `{"atomic": true, "requests": [{"method": "GET", "path": "/v1/movies/2"}, {"method": "GET", "path": "/v1/movies/1"}]}`, http.StatusNotFound, 1, []byte(`"committed": false`)},
+		{"Nested batch", `{"requests": [{"method": "POST", "path": "/v1/batch"}]}`, http.StatusUnprocessableEntity, 0, []byte("must not be a batch request")},
+		{"Empty batch", `{"requests": []}`, http.StatusUnprocessableEntity, 0, []byte("must contain at least 1 request")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.post(t, "/v1/batch", []byte(tt.body))
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			var output struct {
+				Responses []dto.BatchResponse `json:"responses"`
+			}
+			if err := json.Unmarshal(body, &output); err != nil {
+				t.Fatal(err)
+			}
+			if len(output.Responses) != tt.wantResponses {
+				t.Errorf("want %d responses; got %d", tt.wantResponses, len(output.Responses))
+			}
+		})
+	}
+}
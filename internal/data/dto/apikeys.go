@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// APIKeyPrefixLength is the number of leading characters of an API key which are
+// kept in plaintext, so that users can tell their keys apart.
+const APIKeyPrefixLength = 8
+
+// APIKey is a long-lived credential for a service account, sent in an
+// "Authorization: ApiKey <key>" header. Requests made with it are limited to the
+// key's permissions, as far as the user still holds them. The plaintext key is only
+// available when the key is created.
+type APIKey struct {
+	ID          int64       `json:"id"`
+	UserID      int64       `json:"user_id"`
+	Name        string      `json:"name"`
+	Prefix      string      `json:"prefix"`
+	Plaintext   string      `json:"key,omitempty"`
+	Hash        []byte      `json:"-"`
+	Permissions Permissions `json:"permissions"`
+	CreatedAt   time.Time   `json:"created_at"`
+	LastUsedAt  *time.Time  `json:"last_used_at"`
+}
+
+func GenerateAPIKey(userID int64, name string, permissions Permissions) (*APIKey, error) {
+	key := &APIKey{
+		UserID:      userID,
+		Name:        name,
+		Permissions: permissions,
+	}
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	key.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	key.Prefix = key.Plaintext[:APIKeyPrefixLength]
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+	return key, nil
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(strings.TrimSpace(key.Name) != "", "name", "must be provided")
+	v.Check(utf8.RuneCountInString(key.Name) <= 100, "name", "must not be more than 100 characters long")
+	v.Check(len(key.Permissions) >= 1, "permissions", "must contain at least 1 code")
+	v.Check(validator.Unique(key.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range key.Permissions {
+		v.Check(validator.In(code, PermissionList...), "permissions", "invalid permission code value")
+	}
+}
+
+// Check that the plaintext API key has been provided and is exactly 52 bytes long.
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(plaintext != "", "key", "must be provided")
+	v.Check(len(plaintext) == 52, "key", "must be 52 bytes long")
+}
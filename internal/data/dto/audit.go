@@ -0,0 +1,142 @@
+package dto
+
+import (
+	"encoding/json"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"reflect"
+	"time"
+)
+
+// The actions recorded in the audit log.
+const (
+	AuditMovieCreate        = "movie.create"
+	AuditMovieUpdate        = "movie.update"
+	AuditMovieDelete        = "movie.delete"
+	AuditMovieRestore       = "movie.restore"
+	AuditMovieRevert        = "movie.revert"
+	AuditGenreCreate        = "genre.create"
+	AuditGenreRename        = "genre.rename"
+	AuditGenreMerge         = "genre.merge"
+	AuditPermissionsGrant   = "permissions.grant"
+	AuditPermissionsRevoke  = "permissions.revoke"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditRolesAssign        = "roles.assign"
+	AuditRolesUnassign      = "roles.unassign"
+	AuditAPIKeyCreate       = "api_key.create"
+	AuditAPIKeyRevoke       = "api_key.revoke"
+	AuditTOTPEnable         = "totp.enable"
+	AuditTOTPDisable        = "totp.disable"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserEmailChange    = "user.email_change"
+	AuditUserUpdate         = "user.update"
+	AuditUserDelete         = "user.delete"
+	AuditUserDeletionCancel = "user.deletion_cancel"
+)
+
+// The types of object targeted by audited actions.
+const (
+	AuditTargetMovie  = "movie"
+	AuditTargetGenre  = "genre"
+	AuditTargetUser   = "user"
+	AuditTargetRole   = "role"
+	AuditTargetAPIKey = "api_key"
+)
+
+var AuditTargetTypes = []string{AuditTargetMovie, AuditTargetGenre, AuditTargetUser, AuditTargetRole, AuditTargetAPIKey}
+
+// AuditEvent records who made a privileged change, to what, and from where.
+type AuditEvent struct {
+	ID         int64             `json:"id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ActorID    int64             `json:"actor_id"`
+	Action     string            `json:"action"`
+	TargetType string            `json:"target_type"`
+	TargetID   int64             `json:"target_id"`
+	Changes    map[string]Change `json:"changes"`
+	RequestID  string            `json:"request_id"`
+	IP         string            `json:"ip"`
+}
+
+// Change holds the value of a field before and after a mutation. A nil value means
+// that the field wasn't present.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// RedactedValue stands in for the values of a change to personal data, which can't
+// be kept in the audit log as it is append-only.
+const RedactedValue = "[redacted]"
+
+// Redact replaces the values of the changes with RedactedValue, keeping only which
+// fields changed.
+func Redact(changes map[string]Change) map[string]Change {
+	redacted := make(map[string]Change, len(changes))
+	for key := range changes {
+		redacted[key] = Change{Before: RedactedValue, After: RedactedValue}
+	}
+	return redacted
+}
+
+// Diff compares the JSON representations of two values and returns the fields which
+// differ. Either value may be nil, for example when an object is created or deleted.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]Change{}
+	for key, value := range b {
+		if !reflect.DeepEqual(value, a[key]) {
+			changes[key] = Change{Before: value, After: a[key]}
+		}
+	}
+	for key, value := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = Change{Before: nil, After: value}
+		}
+	}
+	return changes, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return fields, nil
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(js, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// AuditFilters holds the criteria used to search the audit log. Zero values mean that
+// the criterion isn't applied.
+type AuditFilters struct {
+	ActorID       int64
+	TargetType    string
+	TargetID      int64
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Filters
+}
+
+func ValidateAuditFilters(v *validator.Validator, f AuditFilters) {
+	ValidateFilters(v, f.Filters)
+	v.Check(f.ActorID >= 0, "actor_id", "must not be negative")
+	v.Check(f.TargetID >= 0, "target_id", "must not be negative")
+	v.Check(f.TargetType == "" || validator.In(f.TargetType, AuditTargetTypes...), "target_type", "invalid target type value")
+	v.Check(f.TargetID == 0 || f.TargetType != "", "target_type", "must be provided with target_id")
+	v.Check(f.CreatedAfter.IsZero() || f.CreatedBefore.IsZero() || f.CreatedAfter.Before(f.CreatedBefore), "created_before", "must be later than created_after")
+}
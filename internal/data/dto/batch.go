@@ -0,0 +1,60 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MaxBatchSize is the largest number of sub-requests accepted in one batch.
+const MaxBatchSize = 50
+
+// BatchRequest is one of the sub-requests of a batch. The body is sent as it is,
+// with a Content-Type of application/json unless the headers say otherwise.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse is the response to one of the sub-requests of a batch. The body is
+// embedded as JSON when it is valid JSON, and as a string otherwise.
+type BatchResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    interface{} `json:"body,omitempty"`
+}
+
+func ValidateBatch(v *validator.Validator, requests []BatchRequest) {
+	v.Check(len(requests) >= 1, "requests", "must contain at least 1 request")
+	v.Check(len(requests) <= MaxBatchSize, "requests", fmt.Sprintf("must not contain more than %d requests", MaxBatchSize))
+	for i, request := range requests {
+		key := fmt.Sprintf("requests[%d]", i)
+		v.Check(validator.In(request.Method, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete), key+".method", "invalid method")
+		v.Check(strings.HasPrefix(request.Path, "/v1/"), key+".path", "must start with /v1/")
+		// Batches can't be nested.
+		v.Check(!strings.HasPrefix(request.Path, "/v1/batch"), key+".path", "must not be a batch request")
+		// Logins record their failures and use up second factors in the database,
+		// which an atomic batch would roll back along with the failed login.
+		v.Check(!isTokensPath(request.Path), key+".path", "must not be a token request")
+		for name := range request.Headers {
+			v.Check(!strings.EqualFold(name, "Authorization"), key+".headers", "must not contain an Authorization header")
+		}
+	}
+}
+
+// isTokensPath reports whether the path, as the router would see it, is one of the
+// /v1/tokens endpoints.
+func isTokensPath(p string) bool {
+	u, err := url.Parse(p)
+	if err != nil {
+		return false
+	}
+	clean := strings.ToLower(path.Clean(u.Path))
+	return clean == "/v1/tokens" || strings.HasPrefix(clean, "/v1/tokens/")
+}
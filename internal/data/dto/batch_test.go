@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"testing"
+)
+
+func TestValidateBatchRefusesTokens(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/v1/movies", true},
+		{"/v1/tokensmith", true},
+		{"/v1/tokens/authentication", false},
+		{"/v1/tokens/2fa", false},
+		{"/v1/tokens/oidc/callback?code=x", false},
+		{"/v1//tokens/authentication", false},
+		{"/v1/movies/../tokens/authentication", false},
+		{"/v1/%74okens/authentication", false},
+		{"/V1/TOKENS/authentication", false},
+	}
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateBatch(v, []BatchRequest{{Method: http.MethodPost, Path: tt.path}})
+		if v.Valid() != tt.want {
+			t.Errorf("%s: want valid %t; got %v", tt.path, tt.want, v.Errors)
+		}
+	}
+}
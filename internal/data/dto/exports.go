@@ -0,0 +1,39 @@
+package dto
+
+import "time"
+
+// The states of a data export.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// ExportTimeout is how long an export can be pending for. An export which is pending
+// for longer was lost, for example because the server stopped while assembling it,
+// and is treated as failed.
+const ExportTimeout = 15 * time.Minute
+
+// DataExport is an archive of a user's personal data. The archive is only loaded
+// when it is downloaded.
+type DataExport struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Status    string    `json:"status"`
+	Expiry    time.Time `json:"expiry"`
+	Archive   []byte    `json:"-"`
+}
+
+// UserComment is a comment along with the movie it was made on, as it appears in a
+// user's data export.
+type UserComment struct {
+	Comment
+	MovieID int64 `json:"movie_id"`
+}
+
+// TokenMetadata describes a token without revealing it.
+type TokenMetadata struct {
+	Scope  string    `json:"scope"`
+	Expiry time.Time `json:"expiry"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Name       string    `json:"name"`
+	MovieCount int       `json:"movie_count"`
+	Version    int32     `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(strings.TrimSpace(genre.Name) != "", "name", "must be provided")
+	v.Check(strings.TrimSpace(genre.Name) == genre.Name, "name", "must not start or end with whitespace")
+	v.Check(utf8.RuneCountInString(genre.Name) <= 50, "name", "must not be more than 50 characters long")
+	v.Check(!strings.Contains(genre.Name, ","), "name", "must not contain commas")
+}
+
+// Check that every genre of a movie was found in the genre catalog. The unknown slice
+// holds the values which couldn't be resolved.
+func ValidateMovieGenres(v *validator.Validator, unknown []string) {
+	v.Check(len(unknown) == 0, "genres", fmt.Sprintf("must only contain known genres (unknown: %s)", strings.Join(unknown, ", ")))
+}
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/kientink26/go-json-api/internal/validator"
+	"net/http"
+	"time"
+	"unicode"
+)
+
+// IdempotencyKey records the response to the first request made with a client's
+// Idempotency-Key header, so that retries of the request can be answered with it.
+// A zero Status means the first request hasn't finished yet.
+type IdempotencyKey struct {
+	UserID      int64
+	Key         string
+	RequestHash []byte
+	Status      int
+	Header      http.Header
+	Body        []byte
+	Expiry      time.Time
+}
+
+func ValidateIdempotencyKey(v *validator.Validator, key string) {
+	v.Check(len(key) <= 255, "Idempotency-Key", "must not be more than 255 bytes long")
+	for _, r := range key {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			v.AddError("Idempotency-Key", "must only contain printable ASCII characters")
+			break
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"strings"
+	"time"
+)
+
+// The kinds of key which failed logins are counted against.
+const (
+	LoginFailureScopeEmail = "email"
+	LoginFailureScopeIP    = "ip"
+)
+
+// The number of failed logins allowed before each further attempt is delayed.
+const freeLoginAttempts = 3
+
+// LoginFailure holds the recent failed logins for an email address or client IP.
+type LoginFailure struct {
+	Scope        string
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// LockoutPolicy decides when logins are delayed and locked. Failures are forgotten
+// once there have been none for Window, and reaching Threshold failures locks logins
+// for Duration.
+type LockoutPolicy struct {
+	Threshold int
+	Window    time.Duration
+	Duration  time.Duration
+}
+
+// RetryAfter returns how long the client must wait before trying to log in again,
+// which is zero if it can try now. After the first few failures the delay doubles
+// with each one, up to the lockout duration.
+func (f *LoginFailure) RetryAfter(policy LockoutPolicy, now time.Time) time.Duration {
+	if f.LockedUntil != nil && f.LockedUntil.After(now) {
+		return f.LockedUntil.Sub(now)
+	}
+	if f.Failures <= freeLoginAttempts || now.Sub(f.LastFailedAt) > policy.Window {
+		return 0
+	}
+	delay := policy.Duration
+	if n := f.Failures - freeLoginAttempts; n < 20 {
+		if d := time.Duration(1<<n) * time.Second; d < delay {
+			delay = d
+		}
+	}
+	if wait := f.LastFailedAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// JustLocked reports whether the failure which was just recorded locked the key.
+func (f *LoginFailure) JustLocked(policy LockoutPolicy) bool {
+	return f.Failures == policy.Threshold
+}
+
+// LoginFailureEmailKey returns the key under which failed logins for an email address
+// are counted.
+func LoginFailureEmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
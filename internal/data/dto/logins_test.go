@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 10, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+	now := time.Unix(1700000000, 0)
+	lockedUntil := now.Add(5 * time.Minute)
+	expiredLock := now.Add(-time.Second)
+	tests := []struct {
+		name    string
+		failure LoginFailure
+		want    time.Duration
+	}{
+		{"Free attempts", LoginFailure{Failures: freeLoginAttempts, LastFailedAt: now}, 0},
+		{"First delay", LoginFailure{Failures: freeLoginAttempts + 1, LastFailedAt: now}, 2 * time.Second},
+		{"Doubled delay", LoginFailure{Failures: freeLoginAttempts + 3, LastFailedAt: now}, 8 * time.Second},
+		{"Partly waited", LoginFailure{Failures: freeLoginAttempts + 3, LastFailedAt: now.Add(-3 * time.Second)}, 5 * time.Second},
+		{"Delay over", LoginFailure{Failures: freeLoginAttempts + 1, LastFailedAt: now.Add(-time.Minute)}, 0},
+		{"Delay capped", LoginFailure{Failures: freeLoginAttempts + 15, LastFailedAt: now}, 15 * time.Minute},
+		{"Very many failures", LoginFailure{Failures: 1000, LastFailedAt: now}, 15 * time.Minute},
+		{"Outside window", LoginFailure{Failures: 9, LastFailedAt: now.Add(-16 * time.Minute)}, 0},
+		{"Locked", LoginFailure{Failures: 10, LastFailedAt: now, LockedUntil: &lockedUntil}, 5 * time.Minute},
+		{"Lock expired", LoginFailure{Failures: 1, LastFailedAt: now.Add(-time.Hour), LockedUntil: &expiredLock}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.failure.RetryAfter(policy, now); got != tt.want {
+				t.Errorf("want %s; got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJustLocked(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 10, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+	tests := []struct {
+		failures int
+		want     bool
+	}{
+		{1, false},
+		{9, false},
+		{10, true},
+		{11, false},
+	}
+	for _, tt := range tests {
+		f := LoginFailure{Failures: tt.failures}
+		if got := f.JustLocked(policy); got != tt.want {
+			t.Errorf("%d failures: want %t; got %t", tt.failures, tt.want, got)
+		}
+	}
+}
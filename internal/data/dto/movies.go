@@ -15,6 +15,12 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
 	Version   int32     `json:"version"`
+	// CreatedBy is the ID of the user who added the movie, or 0 if it isn't known.
+	CreatedBy int64 `json:"created_by,omitempty"`
+	// Highlight holds the title with the words matching a full-text search wrapped
+	// in <b> tags.
+	Highlight string     `json:"highlight,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func ValidateMovie(v *validator.Validator, movie *Movie) {
@@ -30,3 +36,121 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 }
+
+// MovieFilters holds the criteria used to search for movies. A zero value for any of
+// the range bounds means that the bound isn't applied.
+type MovieFilters struct {
+	Title string
+	Query string
+	// Fuzzy holds text which is matched approximately against the title, by
+	// trigram similarity, rather than by full-text search.
+	Fuzzy         string
+	Genres        []string
+	YearMin       int
+	YearMax       int
+	RuntimeMin    int
+	RuntimeMax    int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Filters
+}
+
+// FuzzyText returns the words of a title filter and a q search to match
+// approximately, without the web search syntax of q: quotes and OR are dropped, as
+// are negated words and phrases, which the title shouldn't contain.
+func FuzzyText(title, query string) string {
+	words := strings.Fields(title)
+	for i := 0; i < len(query); {
+		if query[i] == ' ' {
+			i++
+			continue
+		}
+		negated := query[i] == '-'
+		if negated {
+			i++
+		}
+		var term string
+		if i < len(query) && query[i] == '"' {
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			term = query[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			end := strings.IndexByte(query[i:], ' ')
+			if end < 0 {
+				end = len(query) - i
+			}
+			term = query[i : i+end]
+			i += end
+		}
+		if negated || strings.EqualFold(term, "or") {
+			continue
+		}
+		words = append(words, strings.Fields(strings.ReplaceAll(term, `"`, " "))...)
+	}
+	return strings.Join(words, " ")
+}
+
+func ValidateMovieFilters(v *validator.Validator, f MovieFilters) {
+	ValidateFilters(v, f.Filters)
+	v.Check(utf8.RuneCountInString(f.Query) <= 500, "q", "must not be more than 500 characters long")
+	v.Check(f.Sort != "relevance" || f.Query != "", "sort", "relevance sort requires the q parameter")
+	v.Check(f.YearMin >= 0, "year_min", "must not be negative")
+	v.Check(f.YearMax >= 0, "year_max", "must not be negative")
+	v.Check(f.YearMin == 0 || f.YearMax == 0 || f.YearMin <= f.YearMax, "year_max", "must not be less than year_min")
+	v.Check(f.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(f.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	v.Check(f.RuntimeMin == 0 || f.RuntimeMax == 0 || f.RuntimeMin <= f.RuntimeMax, "runtime_max", "must not be less than runtime_min")
+	v.Check(f.CreatedAfter.IsZero() || f.CreatedBefore.IsZero() || f.CreatedAfter.Before(f.CreatedBefore), "created_before", "must be later than created_after")
+}
+
+// The facets which can be requested alongside a movie search.
+var MovieFacetSafelist = []string{"genres", "year", "decade"}
+
+func ValidateMovieFacets(v *validator.Validator, facets []string) {
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+	for _, facet := range facets {
+		v.Check(validator.In(facet, MovieFacetSafelist...), "facets", "invalid facet value")
+	}
+}
+
+// FacetCount holds the number of movies matching a search which share a value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets maps a facet name to the counts for each of its values.
+type Facets map[string][]FacetCount
+
+// MovieSuggestion is the short form of a movie returned by the autocomplete search.
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+func ValidateAutocomplete(v *validator.Validator, prefix string, limit int) {
+	v.Check(strings.TrimSpace(prefix) != "", "prefix", "must be provided")
+	v.Check(utf8.RuneCountInString(prefix) <= 100, "prefix", "must not be more than 100 characters long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+}
+
+// MovieContent holds the editable fields of a movie.
+type MovieContent struct {
+	Title   string   `json:"title"`
+	Year    int32    `json:"year,omitempty"`
+	Runtime Runtime  `json:"runtime,omitempty"`
+	Genres  []string `json:"genres,omitempty"`
+}
+
+// MovieRevision is the state of a movie at one of its versions.
+type MovieRevision struct {
+	MovieID   int64     `json:"movie_id"`
+	Version   int32     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	MovieContent
+}
@@ -0,0 +1,28 @@
+package dto
+
+import "testing"
+
+func TestFuzzyText(t *testing.T) {
+	tests := []struct {
+		title string
+		query string
+		want  string
+	}{
+		{"", "", ""},
+		{"Blade", "", "Blade"},
+		{"", "blade runer", "blade runer"},
+		{"Blade", "runer", "Blade runer"},
+		{"", `"blade runer" 2049`, "blade runer 2049"},
+		{"", "blade OR runer", "blade runer"},
+		{"", "blade or runer", "blade runer"},
+		{"", "blade -runer", "blade"},
+		{"", `blade -"final cut" runer`, "blade runer"},
+		{"", `"blade runer`, "blade runer"},
+		{"", `bl"ade -`, "bl ade"},
+	}
+	for _, tt := range tests {
+		if got := FuzzyText(tt.title, tt.query); got != tt.want {
+			t.Errorf("FuzzyText(%q, %q): want %q; got %q", tt.title, tt.query, tt.want, got)
+		}
+	}
+}
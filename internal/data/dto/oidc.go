@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"crypto/sha256"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"time"
+)
+
+// OIDCLogin is an OpenID Connect login in progress. The state is sent to the identity
+// provider and comes back with the authorization code, and only its hash is stored.
+type OIDCLogin struct {
+	State     string
+	StateHash []byte
+	Verifier  string
+	Nonce     string
+	Expiry    time.Time
+}
+
+// HashState returns the hash under which the login with the given state is stored.
+func HashState(state string) []byte {
+	hash := sha256.Sum256([]byte(state))
+	return hash[:]
+}
+
+func ValidateOIDCCallback(v *validator.Validator, code, state string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 2048, "code", "must not be more than 2048 bytes long")
+	v.Check(state != "", "state", "must be provided")
+	v.Check(len(state) <= 128, "state", "must not be more than 128 bytes long")
+}
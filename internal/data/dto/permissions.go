@@ -1,17 +1,25 @@
 package dto
 
-import "github.com/kientink26/go-json-api/internal/validator"
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"time"
+)
 
 // Define a Permissions slice, which we will use to will hold the permission codes for a single user.
 type Permissions []string
 
 var (
 	MoviesWrite      = "movies:write"
+	MoviesWriteOwn   = "movies:write:own"
 	CommentsWrite    = "comments:write"
 	UsersRead        = "users:read"
+	UsersWrite       = "users:write"
 	PermissionsRead  = "permissions:read"
 	PermissionsWrite = "permissions:write"
-	PermissionList   = Permissions{CommentsWrite, MoviesWrite, UsersRead, PermissionsRead, PermissionsWrite}
+	AuditRead        = "audit:read"
+	PermissionList   = Permissions{CommentsWrite, MoviesWrite, MoviesWriteOwn, UsersRead, UsersWrite, PermissionsRead, PermissionsWrite, AuditRead}
 )
 
 func ValidatePermissions(v *validator.Validator, p Permissions) {
@@ -23,6 +31,41 @@ func ValidatePermissions(v *validator.Validator, p Permissions) {
 	}
 }
 
+// UserPermission is a permission code granted directly to a user. A nil ExpiresAt
+// means that the grant is permanent.
+type UserPermission struct {
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// PermissionGrant is the body of a request granting permissions. It is either a JSON
+// array of codes, for permanent grants, or an object holding the codes and an
+// optional expiry time.
+type PermissionGrant struct {
+	Permissions Permissions `json:"permissions"`
+	ExpiresAt   *time.Time  `json:"expires_at"`
+}
+
+func (g *PermissionGrant) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		g.ExpiresAt = nil
+		return json.Unmarshal(data, &g.Permissions)
+	}
+	// The alias type doesn't have the UnmarshalJSON() method, which stops it from
+	// recursing.
+	type alias PermissionGrant
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*alias)(g))
+}
+
+func ValidatePermissionGrant(v *validator.Validator, g *PermissionGrant) {
+	ValidatePermissions(v, g.Permissions)
+	if g.ExpiresAt != nil {
+		v.Check(g.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+}
+
 // Add a helper method to check whether the Permissions slice contains a specific
 // permission code.
 func (p Permissions) Include(code string) bool {
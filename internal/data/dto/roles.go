@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"github.com/kientink26/go-json-api/internal/validator"
+	"regexp"
+	"time"
+)
+
+var RoleNameRX = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Role is a named set of permission codes which can be assigned to users.
+type Role struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"-"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+	Version     int32       `json:"version"`
+}
+
+func ValidateRole(v *validator.Validator, role *Role) {
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(role.Name, RoleNameRX), "name", "must only contain lowercase letters, digits, hyphens and underscores")
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(validator.Unique(role.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range role.Permissions {
+		v.Check(validator.In(code, PermissionList...), "permissions", "invalid permission code value")
+	}
+}
+
+// Check the names of the roles to assign to, or remove from, a user.
+func ValidateRoleNames(v *validator.Validator, names []string) {
+	v.Check(len(names) >= 1, "roles", "must contain at least 1 role")
+	v.Check(len(names) <= 5, "roles", "must not contain more than 5 roles")
+	v.Check(validator.Unique(names), "roles", "must not contain duplicate values")
+}
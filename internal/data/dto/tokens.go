@@ -12,17 +12,40 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	// A token with the 2fa-pending scope shows that the password has been checked,
+	// and is exchanged for an authentication token along with a second factor.
+	ScopeTwoFactorPending = "2fa-pending"
+	// A token with the email-change scope confirms the new address in its data.
+	ScopeEmailChange = "email-change"
+	// A token with the data-export scope downloads the export whose ID is its data.
+	ScopeDataExport = "data-export"
 )
 
 // Define a Token struct to hold the data for an individual token. This includes the
-// plaintext and hashed versions of the token, associated user ID, expiry time and
-// scope.
+// plaintext and hashed versions of the token, associated user ID, expiry time, scope,
+// any extra data for the scope and the client an authentication token was issued to.
 type Token struct {
 	Plaintext string    `json:"token"`
 	Hash      []byte    `json:"-"`
 	UserID    int64     `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	Data      string    `json:"-"`
+	IP        string    `json:"-"`
+	UserAgent string    `json:"-"`
+}
+
+// Session describes an authentication token without revealing it: when and to which
+// client it was issued, and when and from where it was last used.
+type Session struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	Expiry     time.Time  `json:"expiry"`
+	Current    bool       `json:"current"`
 }
 
 func GenerateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -0,0 +1,93 @@
+package dto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"github.com/kientink26/go-json-api/internal/totp"
+	"github.com/kientink26/go-json-api/internal/validator"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The number of recovery codes handed out when two-factor authentication is enabled.
+const RecoveryCodeCount = 10
+
+var (
+	TOTPCodeRX     = regexp.MustCompile(`^[0-9]{6}$`)
+	RecoveryCodeRX = regexp.MustCompile(`^[A-Z2-7]{5}-?[A-Z2-7]{5}$`)
+)
+
+// TOTP is a user's TOTP secret. It is only used for logging in once ConfirmedAt is
+// set. LastStep is the step of the last accepted code.
+type TOTP struct {
+	UserID      int64      `json:"-"`
+	Secret      string     `json:"secret"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	LastStep    int64      `json:"-"`
+}
+
+func (t *TOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// Check validates a code, refusing codes at or before the step of the last accepted
+// one. It returns the step of the code, which should be saved as the new LastStep.
+func (t *TOTP) Check(code string) (int64, bool, error) {
+	step, ok, err := totp.Validate(code, t.Secret, time.Now())
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	if step <= t.LastStep {
+		return 0, false, nil
+	}
+	return step, true, nil
+}
+
+// GenerateRecoveryCodes returns a set of single-use recovery codes along with their
+// hashes, which is all that is stored. They look like this: 7KQ3M-XJ2PA
+func GenerateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([][]byte, RecoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		randomBytes := make([]byte, 7)
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := encoding.EncodeToString(randomBytes)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, ignoring case and the dash.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ReplaceAll(strings.ToUpper(code), "-", "")
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(validator.Matches(code, TOTPCodeRX), "code", "must be 6 digits")
+}
+
+// ValidateSecondFactor checks that exactly one of a TOTP code and a recovery code has
+// been provided.
+func ValidateSecondFactor(v *validator.Validator, code, recoveryCode string) {
+	switch {
+	case code == "" && recoveryCode == "":
+		v.AddError("code", "must be provided")
+	case code != "" && recoveryCode != "":
+		v.AddError("recovery_code", "must not be provided with code")
+	case code != "":
+		ValidateTOTPCode(v, code)
+	default:
+		v.Check(validator.Matches(strings.ToUpper(recoveryCode), RecoveryCodeRX), "recovery_code", "must be a valid recovery code")
+	}
+}
@@ -1,9 +1,9 @@
 package dto
 
 import (
-	"errors"
+	"fmt"
+	passwords "github.com/kientink26/go-json-api/internal/password"
 	"github.com/kientink26/go-json-api/internal/validator"
-	"golang.org/x/crypto/bcrypt"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -12,6 +12,10 @@ import (
 // Declare a new AnonymousUser variable.
 var AnonymousUser = &User{}
 
+// DeletedUserName replaces the name of a user whose account has been deleted, and
+// their comments are shown as made by a user with only this name.
+const DeletedUserName = "deleted user"
+
 type User struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -19,7 +23,7 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
+	Version   int       `json:"version"`
 }
 
 // Check if a User instance is the AnonymousUser.
@@ -36,15 +40,15 @@ type password struct {
 	Hash      []byte
 }
 
-// The Set() method calculates the bcrypt hash of a plaintext password, and stores both
-// the hash and the plaintext versions in the struct.
+// The Set() method hashes a plaintext password with the default hasher, and stores
+// both the hash and the plaintext versions in the struct.
 func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	hash, err := passwords.Hash(plaintextPassword)
 	if err != nil {
 		return err
 	}
 	p.plaintext = &plaintextPassword
-	p.Hash = hash
+	p.Hash = []byte(hash)
 	return nil
 }
 
@@ -52,16 +56,21 @@ func (p *password) Set(plaintextPassword string) error {
 // hashed password stored in the struct, returning true if it matches and false
 // otherwise.
 func (p *password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.Hash, []byte(plaintextPassword))
-	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, nil
-		default:
-			return false, err
-		}
-	}
-	return true, nil
+	return passwords.Verify(plaintextPassword, string(p.Hash))
+}
+
+// The NeedsRehash() method reports whether the hash was made with another algorithm
+// or outdated parameters, and should be replaced the next time the plaintext
+// password is known.
+func (p *password) NeedsRehash() bool {
+	return passwords.NeedsRehash(string(p.Hash))
+}
+
+// SimulatePasswordCheck does the same work as checking a password, for login attempts
+// with an unknown email address. Otherwise the quicker response would reveal that
+// there is no user with the address.
+func SimulatePasswordCheck(plaintextPassword string) {
+	passwords.SimulateVerify(plaintextPassword)
 }
 
 func ValidateEmail(v *validator.Validator, email string) {
@@ -71,8 +80,28 @@ func ValidateEmail(v *validator.Validator, email string) {
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 	v.Check(strings.TrimSpace(password) != "", "password", "must be provided")
 	v.Check(utf8.RuneCountInString(password) >= 8, "password", "must be at least 8 characters long")
-	v.Check(utf8.RuneCountInString(password) <= 72, "password", "must not be more than 72 characters long")
+	v.Check(utf8.RuneCountInString(password) <= 256, "password", "must not be more than 256 characters long")
+	// Some hashers, such as bcrypt, ignore the end of long passwords.
+	if max := passwords.Default.MaxLength(); max > 0 {
+		v.Check(len(password) <= max, "password", fmt.Sprintf("must not be more than %d bytes long", max))
+	}
+}
+
+// The lowest password.Score() accepted for new passwords.
+const MinPasswordScore = 3
+
+// ValidatePasswordStrength checks that a new password doesn't contain the user's name
+// or email address, and isn't too easy to guess.
+func ValidatePasswordStrength(v *validator.Validator, password, name, email string) {
+	lower := strings.ToLower(password)
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, field := range strings.Fields(strings.ToLower(name)) {
+		v.Check(utf8.RuneCountInString(field) < 3 || !strings.Contains(lower, field), "password", "must not contain your name")
+	}
+	v.Check(utf8.RuneCountInString(local) < 3 || !strings.Contains(lower, local), "password", "must not contain your email address")
+	v.Check(passwords.Score(password, name, email) >= MinPasswordScore, "password", "is too easy to guess, try a longer password or a few uncommon words")
 }
+
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(strings.TrimSpace(user.Name) != "", "name", "must be provided")
 	v.Check(utf8.RuneCountInString(user.Name) <= 500, "name", "must not be more than 500 characters long")
@@ -82,6 +111,7 @@ func ValidateUser(v *validator.Validator, user *User) {
 	// ValidatePasswordPlaintext() helper.
 	if user.Password.plaintext != nil {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+		ValidatePasswordStrength(v, *user.Password.plaintext, user.Name, user.Email)
 	}
 	// If the password hash is ever nil, this will be due to a logic error in our
 	// codebase.
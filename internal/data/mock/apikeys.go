@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+type APIKeyModel struct {
+	Store *Store
+}
+
+func (m APIKeyModel) Insert(key *dto.APIKey) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	key.ID = int64(len(m.Store.apiKeys) + 1)
+	key.CreatedAt = time.Now()
+	k := *key
+	m.Store.apiKeys = append(m.Store.apiKeys, &k)
+	return nil
+}
+
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*dto.APIKey, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	keys := []*dto.APIKey{}
+	for _, key := range m.Store.apiKeys {
+		if key.UserID == userID {
+			k := *key
+			k.Plaintext = ""
+			keys = append(keys, &k)
+		}
+	}
+	return keys, nil
+}
+
+func (m APIKeyModel) GetForKey(plaintext string) (*dto.APIKey, *dto.User, error) {
+	m.Store.mu.Lock()
+	var found *dto.APIKey
+	for _, key := range m.Store.apiKeys {
+		if key.Plaintext == plaintext {
+			k := *key
+			found = &k
+		}
+	}
+	m.Store.mu.Unlock()
+	if found == nil {
+		return nil, nil, postgresql.ErrRecordNotFound
+	}
+	user, err := UserModel{Store: m.Store}.Get(found.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return found, user, nil
+}
+
+func (m APIKeyModel) Delete(id, userID int64) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	for i, key := range m.Store.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			m.Store.apiKeys = append(m.Store.apiKeys[:i], m.Store.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+	return postgresql.ErrRecordNotFound
+}
+
+func (m APIKeyModel) DeleteAllForUser(userID int64) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	keys := m.Store.apiKeys[:0]
+	for _, key := range m.Store.apiKeys {
+		if key.UserID != userID {
+			keys = append(keys, key)
+		}
+	}
+	m.Store.apiKeys = keys
+	return nil
+}
@@ -3,6 +3,7 @@ package mock
 import (
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"strings"
 	"time"
 )
 
@@ -18,10 +19,22 @@ var mockMovie = &dto.Movie{
 
 type MovieModel struct{}
 
-func (m MovieModel) GetAll(title string, genres []string, filters dto.Filters) ([]*dto.Movie, dto.Metadata, error) {
+func (m MovieModel) GetAll(filters dto.MovieFilters) ([]*dto.Movie, dto.Metadata, error) {
 	return []*dto.Movie{mockMovie}, dto.Metadata{}, nil
 }
 
+func (m MovieModel) GetFacets(filters dto.MovieFilters, facets []string) (dto.Facets, error) {
+	return dto.Facets{}, nil
+}
+
+func (m MovieModel) Autocomplete(prefix string, limit int) ([]*dto.MovieSuggestion, error) {
+	suggestions := []*dto.MovieSuggestion{}
+	if strings.HasPrefix(strings.ToLower(mockMovie.Title), strings.ToLower(prefix)) {
+		suggestions = append(suggestions, &dto.MovieSuggestion{ID: mockMovie.ID, Title: mockMovie.Title, Year: mockMovie.Year})
+	}
+	return suggestions, nil
+}
+
 func (m MovieModel) Insert(movie *dto.Movie) error {
 	return nil
 }
@@ -40,3 +53,18 @@ func (m MovieModel) Update(movie *dto.Movie) error {
 func (m MovieModel) Delete(id int64) error {
 	return nil
 }
+func (m MovieModel) GetAllDeleted(filters dto.Filters) ([]*dto.Movie, dto.Metadata, error) {
+	return []*dto.Movie{}, dto.Metadata{}, nil
+}
+
+func (m MovieModel) Restore(id int64) (*dto.Movie, error) {
+	return nil, postgresql.ErrRecordNotFound
+}
+
+func (m MovieModel) Purge(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m MovieModel) Reindex() (int64, error) {
+	return 0, nil
+}
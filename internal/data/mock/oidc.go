@@ -0,0 +1,51 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+type OIDCModel struct {
+	Store *Store
+}
+
+func (m OIDCModel) InsertLogin(login *dto.OIDCLogin) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	l := *login
+	m.Store.logins[login.State] = &l
+	return nil
+}
+
+func (m OIDCModel) TakeLogin(state string) (*dto.OIDCLogin, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	login, ok := m.Store.logins[state]
+	if !ok || !login.Expiry.After(time.Now()) {
+		return nil, postgresql.ErrRecordNotFound
+	}
+	delete(m.Store.logins, state)
+	return login, nil
+}
+
+func (m OIDCModel) DeleteExpiredLogins() (int64, error) {
+	return 0, nil
+}
+
+func (m OIDCModel) GetUserForIdentity(issuer, subject string) (*dto.User, error) {
+	m.Store.mu.Lock()
+	userID, ok := m.Store.identities[issuer+" "+subject]
+	m.Store.mu.Unlock()
+	if !ok {
+		return nil, postgresql.ErrRecordNotFound
+	}
+	return UserModel{Store: m.Store}.Get(userID)
+}
+
+func (m OIDCModel) LinkIdentity(issuer, subject string, userID int64) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	m.Store.identities[issuer+" "+subject] = userID
+	return nil
+}
@@ -0,0 +1,52 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"time"
+)
+
+type PermissionModel struct {
+	Store *Store
+}
+
+func (m PermissionModel) GetAllForUser(userID int64) (dto.Permissions, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	return append(dto.Permissions{}, m.Store.permissions[userID]...), nil
+}
+
+func (m PermissionModel) GetGrantsForUser(userID int64) ([]*dto.UserPermission, error) {
+	return []*dto.UserPermission{}, nil
+}
+
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	for _, code := range codes {
+		if !m.Store.permissions[userID].Include(code) {
+			m.Store.permissions[userID] = append(m.Store.permissions[userID], code)
+		}
+	}
+	return nil
+}
+
+func (m PermissionModel) AddForUserUntil(userID int64, expiresAt time.Time, codes ...string) error {
+	return m.AddForUser(userID, codes...)
+}
+
+func (m PermissionModel) DeleteForUser(userID int64, codes ...string) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	permissions := dto.Permissions{}
+	for _, code := range m.Store.permissions[userID] {
+		if !dto.Permissions(codes).Include(code) {
+			permissions = append(permissions, code)
+		}
+	}
+	m.Store.permissions[userID] = permissions
+	return nil
+}
+
+func (m PermissionModel) DeleteExpired() (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"sync"
+)
+
+// Store keeps the records of the mocked user models in memory, so that what one
+// model writes can be read back through another, as it can from the database.
+type Store struct {
+	mu          sync.Mutex
+	users       []*dto.User
+	tokens      []*dto.Token
+	permissions map[int64]dto.Permissions
+	apiKeys     []*dto.APIKey
+	totp        map[int64]*dto.TOTP
+	logins      map[string]*dto.OIDCLogin
+	identities  map[string]int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		permissions: map[int64]dto.Permissions{},
+		totp:        map[int64]*dto.TOTP{},
+		logins:      map[string]*dto.OIDCLogin{},
+		identities:  map[string]int64{},
+	}
+}
+
+// The user() method returns the user with the given ID, or nil. The caller must hold
+// the lock.
+func (s *Store) user(id int64) *dto.User {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+type TokenModel struct {
+	Store *Store
+}
+
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*dto.Token, error) {
+	return m.NewWithData(userID, ttl, scope, "")
+}
+
+func (m TokenModel) NewWithData(userID int64, ttl time.Duration, scope, data string) (*dto.Token, error) {
+	token, err := dto.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	token.Data = data
+	m.insert(token)
+	return token, nil
+}
+
+func (m TokenModel) NewSession(userID int64, ttl time.Duration, ip, userAgent string) (*dto.Token, error) {
+	token, err := dto.GenerateToken(userID, ttl, dto.ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.IP = ip
+	token.UserAgent = userAgent
+	m.insert(token)
+	return token, nil
+}
+
+func (m TokenModel) insert(token *dto.Token) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	t := *token
+	m.Store.tokens = append(m.Store.tokens, &t)
+}
+
+func (m TokenModel) Get(scope, tokenPlaintext string) (*dto.Token, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	for _, token := range m.Store.tokens {
+		if token.Scope == scope && token.Plaintext == tokenPlaintext && token.Expiry.After(time.Now()) {
+			t := *token
+			return &t, nil
+		}
+	}
+	return nil, postgresql.ErrRecordNotFound
+}
+
+func (m TokenModel) GetForSession(tokenPlaintext, ip string) (*dto.Session, *dto.User, error) {
+	token, err := m.Get(dto.ScopeAuthentication, tokenPlaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := UserModel{Store: m.Store}.Get(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &dto.Session{IP: token.IP, UserAgent: token.UserAgent, Expiry: token.Expiry}, user, nil
+}
+
+func (m TokenModel) GetAllSessionsForUser(userID int64) ([]*dto.Session, error) {
+	return []*dto.Session{}, nil
+}
+
+func (m TokenModel) DeleteSession(id, userID int64) error {
+	return postgresql.ErrRecordNotFound
+}
+
+func (m TokenModel) GetAllForUser(userID int64) ([]*dto.TokenMetadata, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	tokens := []*dto.TokenMetadata{}
+	for _, token := range m.Store.tokens {
+		if token.UserID == userID && token.Expiry.After(time.Now()) {
+			tokens = append(tokens, &dto.TokenMetadata{Scope: token.Scope, Expiry: token.Expiry})
+		}
+	}
+	return tokens, nil
+}
+
+func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	tokens := m.Store.tokens[:0]
+	for _, token := range m.Store.tokens {
+		if token.Scope != scope || token.UserID != userID {
+			tokens = append(tokens, token)
+		}
+	}
+	m.Store.tokens = tokens
+	return nil
+}
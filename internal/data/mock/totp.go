@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+type TOTPModel struct {
+	Store *Store
+}
+
+func (m TOTPModel) Get(userID int64) (*dto.TOTP, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	t, ok := m.Store.totp[userID]
+	if !ok {
+		return nil, postgresql.ErrRecordNotFound
+	}
+	c := *t
+	return &c, nil
+}
+
+func (m TOTPModel) Enroll(t *dto.TOTP) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	t.CreatedAt = time.Now()
+	c := *t
+	m.Store.totp[t.UserID] = &c
+	return nil
+}
+
+func (m TOTPModel) Confirm(t *dto.TOTP, step int64, recoveryHashes [][]byte) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	stored, ok := m.Store.totp[t.UserID]
+	if !ok {
+		return postgresql.ErrRecordNotFound
+	}
+	now := time.Now()
+	stored.ConfirmedAt = &now
+	stored.LastStep = step
+	*t = *stored
+	return nil
+}
+
+func (m TOTPModel) UseStep(userID, step int64) (bool, error) {
+	return false, nil
+}
+
+func (m TOTPModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	return false, nil
+}
+
+func (m TOTPModel) CountRecoveryCodes(userID int64) (int, error) {
+	return 0, nil
+}
+
+func (m TOTPModel) Delete(userID int64) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	if _, ok := m.Store.totp[userID]; !ok {
+		return postgresql.ErrRecordNotFound
+	}
+	delete(m.Store.totp, userID)
+	return nil
+}
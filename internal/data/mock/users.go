@@ -0,0 +1,97 @@
+package mock
+
+import (
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
+)
+
+type UserModel struct {
+	Store *Store
+}
+
+func (m UserModel) GetAll(name string, email string, filters dto.Filters) ([]*dto.User, dto.Metadata, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	users := []*dto.User{}
+	for _, user := range m.Store.users {
+		u := *user
+		users = append(users, &u)
+	}
+	return users, dto.Metadata{}, nil
+}
+
+func (m UserModel) Insert(user *dto.User) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	for _, u := range m.Store.users {
+		if u.Email == user.Email {
+			return postgresql.ErrDuplicateEmail
+		}
+	}
+	user.ID = int64(len(m.Store.users) + 1)
+	user.CreatedAt = time.Now()
+	user.Version = 1
+	u := *user
+	m.Store.users = append(m.Store.users, &u)
+	return nil
+}
+
+func (m UserModel) Get(id int64) (*dto.User, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	user := m.Store.user(id)
+	if user == nil {
+		return nil, postgresql.ErrRecordNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (m UserModel) GetByEmail(email string) (*dto.User, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	for _, user := range m.Store.users {
+		if user.Email == email {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, postgresql.ErrRecordNotFound
+}
+
+func (m UserModel) Update(user *dto.User) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+	stored := m.Store.user(user.ID)
+	if stored == nil || stored.Version != user.Version {
+		return postgresql.ErrEditConflict
+	}
+	user.Version++
+	*stored = *user
+	return nil
+}
+
+func (m UserModel) ScheduleDeletion(id int64, at time.Time) error {
+	return nil
+}
+
+func (m UserModel) CancelDeletion(id int64) error {
+	return postgresql.ErrRecordNotFound
+}
+
+func (m UserModel) GetDueForDeletion() ([]int64, error) {
+	return nil, nil
+}
+
+func (m UserModel) Anonymize(id int64) error {
+	return postgresql.ErrRecordNotFound
+}
+
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*dto.User, error) {
+	token, err := TokenModel{Store: m.Store}.Get(tokenScope, tokenPlaintext)
+	if err != nil {
+		return nil, err
+	}
+	return m.Get(token.UserID)
+}
@@ -5,34 +5,149 @@ import (
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/kientink26/go-json-api/internal/data/mock"
 	"github.com/kientink26/go-json-api/internal/data/postgresql"
+	"time"
 )
 
 type Models struct {
 	Movies interface {
-		GetAll(title string, genres []string, filters dto.Filters) ([]*dto.Movie, dto.Metadata, error)
+		GetAll(filters dto.MovieFilters) ([]*dto.Movie, dto.Metadata, error)
+		GetFacets(filters dto.MovieFilters, facets []string) (dto.Facets, error)
+		Autocomplete(prefix string, limit int) ([]*dto.MovieSuggestion, error)
 		Insert(movie *dto.Movie) error
 		Get(id int64) (*dto.Movie, error)
 		Update(movie *dto.Movie) error
 		Delete(id int64) error
+		GetAllDeleted(filters dto.Filters) ([]*dto.Movie, dto.Metadata, error)
+		Restore(id int64) (*dto.Movie, error)
+		Purge(before time.Time) (int64, error)
+		Reindex() (int64, error)
+	}
+	Users interface {
+		GetAll(name string, email string, filters dto.Filters) ([]*dto.User, dto.Metadata, error)
+		Insert(user *dto.User) error
+		Get(id int64) (*dto.User, error)
+		GetByEmail(email string) (*dto.User, error)
+		Update(user *dto.User) error
+		ScheduleDeletion(id int64, at time.Time) error
+		CancelDeletion(id int64) error
+		GetDueForDeletion() ([]int64, error)
+		Anonymize(id int64) error
+		GetForToken(tokenScope, tokenPlaintext string) (*dto.User, error)
+	}
+	Tokens interface {
+		New(userID int64, ttl time.Duration, scope string) (*dto.Token, error)
+		NewWithData(userID int64, ttl time.Duration, scope, data string) (*dto.Token, error)
+		NewSession(userID int64, ttl time.Duration, ip, userAgent string) (*dto.Token, error)
+		Get(scope, tokenPlaintext string) (*dto.Token, error)
+		GetForSession(tokenPlaintext, ip string) (*dto.Session, *dto.User, error)
+		GetAllSessionsForUser(userID int64) ([]*dto.Session, error)
+		DeleteSession(id, userID int64) error
+		GetAllForUser(userID int64) ([]*dto.TokenMetadata, error)
+		DeleteAllForUser(scope string, userID int64) error
+	}
+	Permissions interface {
+		GetAllForUser(userID int64) (dto.Permissions, error)
+		GetGrantsForUser(userID int64) ([]*dto.UserPermission, error)
+		AddForUser(userID int64, codes ...string) error
+		AddForUserUntil(userID int64, expiresAt time.Time, codes ...string) error
+		DeleteForUser(userID int64, codes ...string) error
+		DeleteExpired() (int64, error)
 	}
-	Users       postgresql.UserModel
-	Tokens      postgresql.TokenModel
-	Permissions postgresql.PermissionModel
 	Comments    postgresql.CommentModel
+	Genres      postgresql.GenreModel
+	Audit       postgresql.AuditModel
+	Revisions   postgresql.MovieRevisionModel
+	Idempotency postgresql.IdempotencyModel
+	Roles       postgresql.RoleModel
+	APIKeys     interface {
+		Insert(key *dto.APIKey) error
+		GetAllForUser(userID int64) ([]*dto.APIKey, error)
+		GetForKey(plaintext string) (*dto.APIKey, *dto.User, error)
+		Delete(id, userID int64) error
+		DeleteAllForUser(userID int64) error
+	}
+	OIDC interface {
+		InsertLogin(login *dto.OIDCLogin) error
+		TakeLogin(state string) (*dto.OIDCLogin, error)
+		DeleteExpiredLogins() (int64, error)
+		GetUserForIdentity(issuer, subject string) (*dto.User, error)
+		LinkIdentity(issuer, subject string, userID int64) error
+	}
+	TOTP interface {
+		Get(userID int64) (*dto.TOTP, error)
+		Enroll(t *dto.TOTP) error
+		Confirm(t *dto.TOTP, step int64, recoveryHashes [][]byte) error
+		UseStep(userID, step int64) (bool, error)
+		UseRecoveryCode(userID int64, code string) (bool, error)
+		CountRecoveryCodes(userID int64) (int, error)
+		Delete(userID int64) error
+	}
+	Logins  postgresql.LoginFailureModel
+	Exports postgresql.DataExportModel
+
+	// The connection pool, which is nil for models bound to a transaction.
+	db           *sql.DB
+	searchConfig string
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels returns the models backed by the given connection pool. The
+// searchConfig is the PostgreSQL text search configuration used for movie titles.
+func NewModels(db *sql.DB, searchConfig string) Models {
+	models := newModels(db, searchConfig)
+	models.db = db
+	return models
+}
+
+func newModels(db postgresql.DBTX, searchConfig string) Models {
 	return Models{
-		Movies:      postgresql.MovieModel{DB: db},
-		Users:       postgresql.UserModel{DB: db},
-		Tokens:      postgresql.TokenModel{DB: db},
-		Permissions: postgresql.PermissionModel{DB: db},
-		Comments:    postgresql.CommentModel{DB: db},
+		Movies:       postgresql.MovieModel{DB: db, SearchConfig: searchConfig},
+		Users:        postgresql.UserModel{DB: db},
+		Tokens:       postgresql.TokenModel{DB: db},
+		Permissions:  postgresql.PermissionModel{DB: db},
+		Comments:     postgresql.CommentModel{DB: db},
+		Genres:       postgresql.GenreModel{DB: db},
+		Audit:        postgresql.AuditModel{DB: db},
+		Revisions:    postgresql.MovieRevisionModel{DB: db},
+		Idempotency:  postgresql.IdempotencyModel{DB: db},
+		Roles:        postgresql.RoleModel{DB: db},
+		APIKeys:      postgresql.APIKeyModel{DB: db},
+		OIDC:         postgresql.OIDCModel{DB: db},
+		TOTP:         postgresql.TOTPModel{DB: db},
+		Logins:       postgresql.LoginFailureModel{DB: db},
+		Exports:      postgresql.DataExportModel{DB: db},
+		searchConfig: searchConfig,
 	}
 }
 
 func NewMockModels() Models {
+	store := mock.NewStore()
 	return Models{
-		Movies: mock.MovieModel{},
+		Movies:      mock.MovieModel{},
+		Users:       mock.UserModel{Store: store},
+		Tokens:      mock.TokenModel{Store: store},
+		Permissions: mock.PermissionModel{Store: store},
+		APIKeys:     mock.APIKeyModel{Store: store},
+		OIDC:        mock.OIDCModel{Store: store},
+		TOTP:        mock.TOTPModel{Store: store},
+	}
+}
+
+// Transaction calls fn with a copy of the models bound to a single database
+// transaction, which is committed if fn returns nil and rolled back otherwise.
+// Models which are already bound to a transaction, or mocked, are passed to fn as
+// they are.
+func (m Models) Transaction(fn func(m Models) error) error {
+	if m.db == nil {
+		return fn(m)
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(newModels(tx, m.searchConfig))
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
+	return tx.Commit()
 }
@@ -0,0 +1,138 @@
+package postgresql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+type APIKeyModel struct {
+	DB DBTX
+}
+
+func (m APIKeyModel) Insert(key *dto.APIKey) error {
+	query := `
+INSERT INTO api_keys (user_id, name, prefix, hash, permissions)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at`
+	args := []interface{}{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Permissions)}
+	err := m.DB.QueryRow(query, args...).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), `violates foreign key constraint "api_keys_user_id_fkey"`):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*dto.APIKey, error) {
+	query := `
+SELECT id, user_id, name, prefix, permissions, created_at, last_used_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY id`
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []*dto.APIKey{}
+	for rows.Next() {
+		var key dto.APIKey
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Permissions),
+			&key.CreatedAt,
+			&key.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// The GetForKey() method returns the API key matching the plaintext key, together
+// with the user it belongs to. The time the key was last used is updated, at most
+// once a minute to save on writes.
+func (m APIKeyModel) GetForKey(plaintext string) (*dto.APIKey, *dto.User, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+	query := `
+SELECT api_keys.id, api_keys.name, api_keys.prefix, api_keys.permissions, api_keys.created_at, api_keys.last_used_at,
+	users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+FROM api_keys
+INNER JOIN users ON users.id = api_keys.user_id
+WHERE api_keys.hash = $1`
+	var key dto.APIKey
+	var user dto.User
+	err := m.DB.QueryRow(query, hash[:]).Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Permissions),
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	key.UserID = user.ID
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > time.Minute {
+		_, err = m.DB.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, key.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return &key, &user, nil
+}
+
+// The Delete() method revokes one of a user's API keys.
+func (m APIKeyModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	result, err := m.DB.Exec(`DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The DeleteAllForUser() method revokes every API key of a user.
+func (m APIKeyModel) DeleteAllForUser(userID int64) error {
+	_, err := m.DB.Exec(`DELETE FROM api_keys WHERE user_id = $1`, userID)
+	return err
+}
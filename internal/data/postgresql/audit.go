@@ -0,0 +1,84 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+)
+
+type AuditModel struct {
+	DB DBTX
+}
+
+// Insert() appends an event to the audit log. To keep the log consistent it should
+// run in the same transaction as the change being recorded.
+func (m AuditModel) Insert(event *dto.AuditEvent) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+	query := `
+INSERT INTO audit_events (actor_id, action, target_type, target_id, changes, request_id, ip)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at`
+	args := []interface{}{event.ActorID, event.Action, event.TargetType, event.TargetID, changes, event.RequestID, event.IP}
+	return m.DB.QueryRow(query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+func (m AuditModel) GetAll(filters dto.AuditFilters) ([]*dto.AuditEvent, dto.Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, actor_id, action, target_type, target_id, changes, request_id, ip
+FROM audit_events
+WHERE (actor_id = $1 OR $1 = 0)
+AND (target_type = $2 OR $2 = '')
+AND (target_id = $3 OR $3 = 0)
+AND (created_at > $4 OR $4 IS NULL)
+AND (created_at < $5 OR $5 IS NULL)
+ORDER BY %s %s, id ASC
+LIMIT $6 OFFSET $7`, filters.SortColumn(), filters.SortDirection())
+	args := []interface{}{
+		filters.ActorID,
+		filters.TargetType,
+		filters.TargetID,
+		nullTime(filters.CreatedAfter),
+		nullTime(filters.CreatedBefore),
+		filters.Limit(),
+		filters.Offset(),
+	}
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	events := []*dto.AuditEvent{}
+	for rows.Next() {
+		var event dto.AuditEvent
+		var changes []byte
+		err := rows.Scan(
+			&totalRecords,
+			&event.ID,
+			&event.CreatedAt,
+			&event.ActorID,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&changes,
+			&event.RequestID,
+			&event.IP,
+		)
+		if err != nil {
+			return nil, dto.Metadata{}, err
+		}
+		err = json.Unmarshal(changes, &event.Changes)
+		if err != nil {
+			return nil, dto.Metadata{}, err
+		}
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	metadata := dto.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return events, metadata, nil
+}
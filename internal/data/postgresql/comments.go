@@ -2,23 +2,28 @@ package postgresql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"strings"
 )
 
 type CommentModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func (m CommentModel) Insert(comment *dto.Comment, userID int64, movieID int64) error {
+	// Movies in the trash can't be commented on, so the movie is selected rather than
+	// relying on the foreign key alone.
 	query := `INSERT INTO comments (body, user_id, movie_id)
-			VALUES ($1, $2, $3)
+			SELECT $1, $2, movies.id FROM movies WHERE movies.id = $3 AND movies.deleted_at IS NULL
 			RETURNING id, created_at`
 	args := []interface{}{comment.Body, userID, movieID}
 	err := m.DB.QueryRow(query, args...).Scan(&comment.ID, &comment.CreatedAt)
 	if err != nil {
 		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
 		case strings.Contains(err.Error(), `violates foreign key constraint "comments_movie_id_fkey"`):
 			return ErrRecordNotFound
 		case strings.Contains(err.Error(), `violates foreign key constraint "comments_user_id_fkey"`):
@@ -32,11 +37,11 @@ func (m CommentModel) Insert(comment *dto.Comment, userID int64, movieID int64)
 
 func (m CommentModel) GetAllForMovie(movieID int64, filters dto.Filters) ([]*dto.CommentUser, dto.Metadata, error) {
 	query := fmt.Sprintf(`SELECT count(*) OVER(), comments.id, comments.created_at, comments.body, 
-       									users.id, users.name, users.email, users.activated, users.created_at
+       									users.id, users.name, users.email, users.activated, users.created_at, users.deleted_at IS NOT NULL
 			FROM comments
 			INNER JOIN movies ON comments.movie_id = movies.id
 			INNER JOIN users ON comments.user_id = users.id
-			WHERE movies.id = $1
+			WHERE movies.id = $1 AND movies.deleted_at IS NULL
 			ORDER BY comments.%s %s, comments.id ASC`, filters.SortColumn(), filters.SortDirection())
 	rows, err := m.DB.Query(query, movieID)
 	if err != nil {
@@ -48,6 +53,7 @@ func (m CommentModel) GetAllForMovie(movieID int64, filters dto.Filters) ([]*dto
 	// Use rows.Next to iterate through the rows in the resultset.
 	for rows.Next() {
 		var comment dto.CommentUser
+		var deleted bool
 		err := rows.Scan(
 			&totalRecords, // Scan the count from the window function into totalRecords.
 			&comment.ID,
@@ -58,10 +64,15 @@ func (m CommentModel) GetAllForMovie(movieID int64, filters dto.Filters) ([]*dto
 			&comment.User.Email,
 			&comment.User.Activated,
 			&comment.User.CreatedAt,
+			&deleted,
 		)
 		if err != nil {
 			return nil, dto.Metadata{}, err
 		}
+		// Comments by a deleted user stay, but nothing else about them is shown.
+		if deleted {
+			comment.User = dto.User{Name: dto.DeletedUserName}
+		}
 		comments = append(comments, &comment)
 	}
 	// When the rows.Next() loop has finished, call rows.Err() to retrieve any error
@@ -71,3 +82,30 @@ func (m CommentModel) GetAllForMovie(movieID int64, filters dto.Filters) ([]*dto
 	}
 	return comments, dto.Metadata{TotalRecords: totalRecords}, nil
 }
+
+// The GetAllForUser() method returns every comment a user has made, oldest first.
+func (m CommentModel) GetAllForUser(userID int64) ([]*dto.UserComment, error) {
+	query := `
+SELECT id, created_at, body, movie_id
+FROM comments
+WHERE user_id = $1
+ORDER BY id`
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []*dto.UserComment{}
+	for rows.Next() {
+		var comment dto.UserComment
+		err := rows.Scan(&comment.ID, &comment.CreatedAt, &comment.Body, &comment.MovieID)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &comment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,31 @@
+package postgresql
+
+import "database/sql"
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so that a model can run its
+// queries against the connection pool or inside a transaction.
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// The withTx() helper runs fn inside a transaction, committing it if fn returns nil
+// and rolling it back otherwise. If db is already a transaction, fn simply joins it
+// and the owner of that transaction decides whether to commit.
+func withTx(db DBTX, fn func(tx DBTX) error) error {
+	pool, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := pool.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,107 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"time"
+)
+
+var (
+	ErrExportExists = errors.New("data export already exists")
+)
+
+type DataExportModel struct {
+	DB DBTX
+}
+
+// The Insert() method adds a pending export for a user. It returns ErrExportExists if
+// the user already has one which is pending or can still be downloaded, so that each
+// user only has one archive at a time. Exports which have been pending for longer than
+// dto.ExportTimeout are marked as failed first, so that they don't block new ones.
+func (m DataExportModel) Insert(export *dto.DataExport) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		// Lock the user, so that concurrent requests can't both find no export.
+		_, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, export.UserID)
+		if err != nil {
+			return err
+		}
+		query := `
+UPDATE data_exports
+SET status = $1
+WHERE user_id = $2 AND status = $3 AND created_at < $4`
+		_, err = tx.Exec(query, dto.ExportFailed, export.UserID, dto.ExportPending, time.Now().Add(-dto.ExportTimeout))
+		if err != nil {
+			return err
+		}
+		query = `
+INSERT INTO data_exports (user_id, expiry)
+SELECT $1, $2
+WHERE NOT EXISTS (
+	SELECT 1 FROM data_exports
+	WHERE user_id = $1 AND status IN ($3, $4) AND expiry > NOW()
+)
+RETURNING id, created_at, status`
+		args := []interface{}{export.UserID, export.Expiry, dto.ExportPending, dto.ExportReady}
+		err = tx.QueryRow(query, args...).Scan(&export.ID, &export.CreatedAt, &export.Status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrExportExists
+		}
+		return err
+	})
+}
+
+// The Get() method returns one of a user's unexpired exports, along with its archive
+// if it is ready. An export which has been pending for longer than dto.ExportTimeout
+// is reported as failed.
+func (m DataExportModel) Get(id, userID int64) (*dto.DataExport, error) {
+	query := `
+SELECT id, user_id, created_at,
+	CASE WHEN status = $3 AND created_at < $4 THEN $5 ELSE status END,
+	expiry, archive
+FROM data_exports
+WHERE id = $1 AND user_id = $2 AND expiry > NOW()`
+	args := []interface{}{id, userID, dto.ExportPending, time.Now().Add(-dto.ExportTimeout), dto.ExportFailed}
+	var export dto.DataExport
+	err := m.DB.QueryRow(query, args...).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.CreatedAt,
+		&export.Status,
+		&export.Expiry,
+		&export.Archive,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &export, nil
+}
+
+// The Complete() method stores the archive of a pending export, or marks it as failed
+// if the archive is nil.
+func (m DataExportModel) Complete(export *dto.DataExport) error {
+	export.Status = dto.ExportReady
+	if export.Archive == nil {
+		export.Status = dto.ExportFailed
+	}
+	query := `
+UPDATE data_exports
+SET status = $1, archive = $2
+WHERE id = $3 AND status = 'pending'`
+	_, err := m.DB.Exec(query, export.Status, export.Archive, export.ID)
+	return err
+}
+
+// The DeleteExpired() method removes the exports which can no longer be downloaded.
+func (m DataExportModel) DeleteExpired() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM data_exports WHERE expiry <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
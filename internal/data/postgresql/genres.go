@@ -0,0 +1,222 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/lib/pq"
+	"strings"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+)
+
+// rewriteGenreQuery replaces the genre $1 (compared case-insensitively) with $2 in every
+// movie that uses it, keeping the original order and dropping any duplicate which the
+// replacement creates. The new version of each movie is added to its revisions.
+const rewriteGenreQuery = `
+WITH updated AS (
+	UPDATE movies
+	SET genres = ARRAY(
+			SELECT s.g FROM (
+				SELECT CASE WHEN lower(t.g) = lower($1) THEN $2 ELSE t.g END AS g, min(t.i) AS i
+				FROM unnest(genres) WITH ORDINALITY AS t(g, i)
+				GROUP BY 1) AS s
+			ORDER BY s.i),
+		version = version + 1
+	WHERE EXISTS (SELECT 1 FROM unnest(genres) AS g WHERE lower(g) = lower($1))
+	RETURNING id, version, title, year, runtime, genres
+)
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres)
+SELECT id, version, title, year, runtime, genres FROM updated`
+
+// genreMovieCountQuery counts the movies outside the trash which use the genre $1.
+const genreMovieCountQuery = `SELECT count(*) FROM movies WHERE genres @> ARRAY[$1::text] AND deleted_at IS NULL`
+
+type GenreModel struct {
+	DB DBTX
+}
+
+// The GetAll() method returns every genre in the catalog along with the number of
+// movies using it.
+func (m GenreModel) GetAll(filters dto.Filters) ([]*dto.Genre, error) {
+	query := fmt.Sprintf(`
+SELECT genres.id, genres.created_at, genres.name, genres.version, count(movies.id) AS movie_count
+FROM genres
+LEFT JOIN movies ON movies.genres @> ARRAY[genres.name::text] AND movies.deleted_at IS NULL
+GROUP BY genres.id
+ORDER BY %s %s, genres.id ASC`, filters.SortColumn(), filters.SortDirection())
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	genres := []*dto.Genre{}
+	for rows.Next() {
+		var genre dto.Genre
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Name,
+			&genre.Version,
+			&genre.MovieCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+func (m GenreModel) Get(id int64) (*dto.Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT genres.id, genres.created_at, genres.name, genres.version, count(movies.id)
+FROM genres
+LEFT JOIN movies ON movies.genres @> ARRAY[genres.name::text] AND movies.deleted_at IS NULL
+WHERE genres.id = $1
+GROUP BY genres.id`
+	var genre dto.Genre
+	err := m.DB.QueryRow(query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Name,
+		&genre.Version,
+		&genre.MovieCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &genre, nil
+}
+
+func (m GenreModel) Insert(genre *dto.Genre) error {
+	query := `
+INSERT INTO genres (name)
+VALUES ($1)
+RETURNING id, created_at, version`
+	err := m.DB.QueryRow(query, genre.Name).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), `violates unique constraint "genres_name_key"`):
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// The Rename() method changes the name of a genre and rewrites every movie which
+// used the old name, all in a single transaction.
+func (m GenreModel) Rename(genre *dto.Genre, oldName string) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+UPDATE genres
+SET name = $1, version = version + 1
+WHERE id = $2 AND version = $3
+RETURNING version`
+		err := tx.QueryRow(query, genre.Name, genre.ID, genre.Version).Scan(&genre.Version)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `violates unique constraint "genres_name_key"`):
+				return ErrDuplicateGenre
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		_, err = tx.Exec(rewriteGenreQuery, oldName, genre.Name)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(genreMovieCountQuery, genre.Name).Scan(&genre.MovieCount)
+	})
+}
+
+// The Merge() method folds the source genre into the target genre: every movie using
+// the source is rewritten to use the target instead, and the source is removed from
+// the catalog.
+func (m GenreModel) Merge(source, target *dto.Genre) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		_, err := tx.Exec(rewriteGenreQuery, source.Name, target.Name)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM genres WHERE id = $1 AND version = $2`, source.ID, source.Version)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrEditConflict
+		}
+		query := `
+UPDATE genres
+SET version = version + 1
+WHERE id = $1 AND version = $2
+RETURNING version`
+		err = tx.QueryRow(query, target.ID, target.Version).Scan(&target.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		return tx.QueryRow(genreMovieCountQuery, target.Name).Scan(&target.MovieCount)
+	})
+}
+
+// The Resolve() method looks up the given names in the genre catalog, ignoring case.
+// It returns the catalog spelling of each known name, in the same order as the input,
+// together with the names which aren't in the catalog.
+func (m GenreModel) Resolve(names []string) ([]string, []string, error) {
+	query := `
+SELECT t.name, genres.name
+FROM unnest($1::text[]) WITH ORDINALITY AS t(name, i)
+LEFT JOIN genres ON genres.name = t.name::citext
+ORDER BY t.i`
+	rows, err := m.DB.Query(query, pq.Array(names))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	resolved := []string{}
+	unknown := []string{}
+	for rows.Next() {
+		var name string
+		var canonical sql.NullString
+		err := rows.Scan(&name, &canonical)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !canonical.Valid {
+			unknown = append(unknown, name)
+			continue
+		}
+		resolved = append(resolved, canonical.String)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return resolved, unknown, nil
+}
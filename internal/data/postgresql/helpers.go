@@ -0,0 +1,15 @@
+package postgresql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// likeEscaper escapes the wildcard characters of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// nullTime converts the zero time into a SQL NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,93 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"time"
+)
+
+type IdempotencyModel struct {
+	DB DBTX
+}
+
+// The Reserve() method claims an idempotency key for a request. If the key is free,
+// or its previous use has expired, it is claimed and nil is returned. Otherwise the
+// existing record is returned, so that the caller can replay the stored response or
+// reject the request.
+func (m IdempotencyModel) Reserve(userID int64, key string, requestHash []byte, ttl time.Duration) (*dto.IdempotencyKey, error) {
+	query := `
+INSERT INTO idempotency_keys (user_id, key, request_hash, expiry)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash, status = NULL, header = NULL, body = NULL,
+	created_at = NOW(), expiry = EXCLUDED.expiry
+WHERE idempotency_keys.expiry < NOW()
+RETURNING user_id`
+	err := m.DB.QueryRow(query, userID, key, requestHash, time.Now().Add(ttl)).Scan(&userID)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	// The key is in use. The row may have been released since the insert, in which
+	// case the caller is told that the request is still being processed and can retry.
+	query = `
+SELECT request_hash, status, header, body, expiry
+FROM idempotency_keys
+WHERE user_id = $1 AND key = $2`
+	record := dto.IdempotencyKey{UserID: userID, Key: key}
+	var status sql.NullInt32
+	var header []byte
+	err = m.DB.QueryRow(query, userID, key).Scan(&record.RequestHash, &status, &header, &record.Body, &record.Expiry)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		record.RequestHash = requestHash
+		return &record, nil
+	case err != nil:
+		return nil, err
+	}
+	record.Status = int(status.Int32)
+	if header != nil {
+		err = json.Unmarshal(header, &record.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+// The Complete() method stores the response to the request which reserved the key.
+func (m IdempotencyModel) Complete(record *dto.IdempotencyKey) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	query := `
+UPDATE idempotency_keys
+SET status = $1, header = $2, body = $3
+WHERE user_id = $4 AND key = $5`
+	_, err = m.DB.Exec(query, record.Status, header, record.Body, record.UserID, record.Key)
+	return err
+}
+
+// The Release() method frees a key whose request didn't complete, so that it can be
+// retried.
+func (m IdempotencyModel) Release(userID int64, key string) error {
+	query := `
+DELETE FROM idempotency_keys
+WHERE user_id = $1 AND key = $2 AND status IS NULL`
+	_, err := m.DB.Exec(query, userID, key)
+	return err
+}
+
+// The DeleteExpired() method removes the keys which have passed their expiry time.
+func (m IdempotencyModel) DeleteExpired() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM idempotency_keys WHERE expiry < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
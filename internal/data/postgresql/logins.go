@@ -0,0 +1,94 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"time"
+)
+
+type LoginFailureModel struct {
+	DB DBTX
+}
+
+func (m LoginFailureModel) Get(scope, key string) (*dto.LoginFailure, error) {
+	query := `
+SELECT scope, key, failures, last_failed_at, locked_until
+FROM login_failures
+WHERE scope = $1 AND key = $2`
+	var f dto.LoginFailure
+	err := m.DB.QueryRow(query, scope, key).Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailedAt, &f.LockedUntil)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &f, nil
+}
+
+// The Record() method counts a failed login against the key, starting the count
+// again if the last failure is older than the policy's window, and locks the key
+// when the count reaches the threshold.
+func (m LoginFailureModel) Record(scope, key string, policy dto.LockoutPolicy) (*dto.LoginFailure, error) {
+	query := `
+INSERT INTO login_failures AS f (scope, key, locked_until)
+VALUES ($1, $2, CASE WHEN $3 <= 1 THEN NOW() + $5 * interval '1 second' END)
+ON CONFLICT (scope, key) DO UPDATE
+SET failures = CASE WHEN f.last_failed_at < NOW() - $4 * interval '1 second' THEN 1 ELSE f.failures + 1 END,
+	last_failed_at = NOW(),
+	locked_until = CASE
+		WHEN f.last_failed_at >= NOW() - $4 * interval '1 second' AND f.failures + 1 >= $3
+		THEN NOW() + $5 * interval '1 second'
+		ELSE f.locked_until END
+RETURNING scope, key, failures, last_failed_at, locked_until`
+	args := []interface{}{scope, key, policy.Threshold, policy.Window.Seconds(), policy.Duration.Seconds()}
+	var f dto.LoginFailure
+	err := m.DB.QueryRow(query, args...).Scan(&f.Scope, &f.Key, &f.Failures, &f.LastFailedAt, &f.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// The Reset() method forgets the failed logins for a key, after a successful login.
+func (m LoginFailureModel) Reset(scope, key string) error {
+	_, err := m.DB.Exec(`DELETE FROM login_failures WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
+
+// The ResetForUser() method unlocks the email address of a user. It returns
+// ErrRecordNotFound if there were no failed logins for it.
+func (m LoginFailureModel) ResetForUser(userID int64) error {
+	query := `
+DELETE FROM login_failures
+USING users
+WHERE users.id = $1 AND login_failures.scope = $2 AND login_failures.key = lower(users.email)`
+	result, err := m.DB.Exec(query, userID, dto.LoginFailureScopeEmail)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The DeleteStale() method removes the counts which have no failures since before and
+// aren't locked.
+func (m LoginFailureModel) DeleteStale(before time.Time) (int64, error) {
+	query := `
+DELETE FROM login_failures
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW())`
+	result, err := m.DB.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
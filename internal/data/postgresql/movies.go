@@ -6,23 +6,91 @@ import (
 	"fmt"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/lib/pq"
+	"time"
 )
 
 type MovieModel struct {
-	DB *sql.DB
+	DB DBTX
+	// SearchConfig is the PostgreSQL text search configuration (e.g. "english") used
+	// to index and search movie titles.
+	SearchConfig string
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters dto.Filters) ([]*dto.Movie, dto.Metadata, error) {
-	// Construct the SQL query to retrieve all movie records.
+// movieFilterClause is the WHERE clause shared by the movie search queries. Its
+// parameters are supplied by the filterArgs() method. The title filter matches all
+// of its words, while the q filter accepts web search syntax: "quoted phrases", OR
+// and -negation. Movies in the trash are never matched.
+const movieFilterClause = `
+WHERE deleted_at IS NULL
+AND (search_vector @@ plainto_tsquery($1::regconfig, $2) OR $2 = '')
+AND (search_vector @@ websearch_to_tsquery($1::regconfig, $3) OR $3 = '')
+AND (genres @> $4 OR $4 = '{}')
+AND (year >= $5 OR $5 = 0)
+AND (year <= $6 OR $6 = 0)
+AND (runtime >= $7 OR $7 = 0)
+AND (runtime <= $8 OR $8 = 0)
+AND (created_at > $9 OR $9 IS NULL)
+AND (created_at < $10 OR $10 IS NULL)
+AND ($11 <% title OR $11 = '')`
+
+func (m MovieModel) filterArgs(f dto.MovieFilters) []interface{} {
+	return []interface{}{
+		m.SearchConfig,
+		f.Title,
+		f.Query,
+		pq.Array(f.Genres),
+		f.YearMin,
+		f.YearMax,
+		f.RuntimeMin,
+		f.RuntimeMax,
+		nullTime(f.CreatedAfter),
+		nullTime(f.CreatedBefore),
+		f.Fuzzy,
+	}
+}
+
+// The facet queries, each returning a value and the number of matching movies which
+// share it.
+var movieFacetQueries = map[string]string{
+	"genres": `
+SELECT g, count(*)
+FROM movies, unnest(genres) AS g` + movieFilterClause + `
+GROUP BY g
+ORDER BY count(*) DESC, g ASC`,
+	"year": `
+SELECT year::text, count(*)
+FROM movies` + movieFilterClause + `
+GROUP BY year
+ORDER BY year DESC`,
+	"decade": `
+SELECT decade::text || 's', count(*)
+FROM (SELECT year / 10 * 10 AS decade FROM movies` + movieFilterClause + `) AS d
+GROUP BY decade
+ORDER BY decade DESC`,
+}
+
+func (m MovieModel) GetAll(filters dto.MovieFilters) ([]*dto.Movie, dto.Metadata, error) {
+	orderBy := fmt.Sprintf("%s %s", filters.SortColumn(), filters.SortDirection())
+	if filters.SortColumn() == "relevance" {
+		// The most relevant movies come first.
+		orderBy = "ts_rank(search_vector, websearch_to_tsquery($1::regconfig, $3)) DESC"
+		if filters.Fuzzy != "" {
+			orderBy = "word_similarity($11, title) DESC"
+		}
+	}
+	// Construct the SQL query to retrieve all movie records. When searching with q,
+	// the matching words of each title are highlighted with <b> tags.
 	query := fmt.Sprintf(`
-SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
-FROM movies
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-AND (genres @> $2 OR $2 = '{}')
-ORDER BY %s %s, id ASC
-LIMIT $3 OFFSET $4`, filters.SortColumn(), filters.SortDirection())
+SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, COALESCE(created_by, 0),
+	CASE WHEN $3 = '' THEN ''
+	ELSE ts_headline($1::regconfig, title, websearch_to_tsquery($1::regconfig, $3), 'HighlightAll=true')
+	END
+FROM movies%s
+ORDER BY %s, id ASC
+LIMIT $12 OFFSET $13`, movieFilterClause, orderBy)
 
-	rows, err := m.DB.Query(query, title, pq.Array(genres), filters.Limit(), filters.Offset())
+	args := append(m.filterArgs(filters), filters.Limit(), filters.Offset())
+	rows, err := m.DB.Query(query, args...)
 	if err != nil {
 		return nil, dto.Metadata{}, err
 	}
@@ -43,6 +111,8 @@ LIMIT $3 OFFSET $4`, filters.SortColumn(), filters.SortDirection())
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.CreatedBy,
+			&movie.Highlight,
 		)
 		if err != nil {
 			return nil, dto.Metadata{}, err
@@ -54,17 +124,101 @@ LIMIT $3 OFFSET $4`, filters.SortColumn(), filters.SortDirection())
 	if err = rows.Err(); err != nil {
 		return nil, dto.Metadata{}, err
 	}
+	// A page past the end has no rows to count the matches with, so they are counted
+	// separately.
+	if len(movies) == 0 && filters.Page > 1 {
+		err = m.DB.QueryRow(`SELECT count(*) FROM movies`+movieFilterClause, m.filterArgs(filters)...).Scan(&totalRecords)
+		if err != nil {
+			return nil, dto.Metadata{}, err
+		}
+	}
 	metadata := dto.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
 	return movies, metadata, nil
 }
 
+// The GetFacets() method counts the movies matching the filters by each of the
+// requested facets. Pagination is ignored, so the counts cover the whole result set.
+func (m MovieModel) GetFacets(filters dto.MovieFilters, facets []string) (dto.Facets, error) {
+	result := dto.Facets{}
+	for _, facet := range facets {
+		query, ok := movieFacetQueries[facet]
+		if !ok {
+			panic("unsafe facet parameter: " + facet)
+		}
+		counts, err := m.getFacet(query, m.filterArgs(filters))
+		if err != nil {
+			return nil, err
+		}
+		result[facet] = counts
+	}
+	return result, nil
+}
+
+func (m MovieModel) getFacet(query string, args []interface{}) ([]dto.FacetCount, error) {
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []dto.FacetCount{}
+	for rows.Next() {
+		var count dto.FacetCount
+		err := rows.Scan(&count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// The Autocomplete() method returns up to limit movies whose title starts with the
+// prefix, followed by the titles containing a word similar to it, so that typos
+// still find a match.
+func (m MovieModel) Autocomplete(prefix string, limit int) ([]*dto.MovieSuggestion, error) {
+	query := `
+SELECT id, title, year
+FROM movies
+WHERE deleted_at IS NULL AND (title ILIKE $1 || '%' OR $2 <% title)
+ORDER BY title ILIKE $1 || '%' DESC, word_similarity($2, title) DESC, title ASC
+LIMIT $3`
+	rows, err := m.DB.Query(query, likeEscaper.Replace(prefix), prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suggestions := []*dto.MovieSuggestion{}
+	for rows.Next() {
+		var suggestion dto.MovieSuggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
 func (m MovieModel) Insert(movie *dto.Movie) error {
 	query := `
-INSERT INTO movies (title, year, runtime, genres)
-VALUES ($1, $2, $3, $4)
+INSERT INTO movies (title, year, runtime, genres, search_config, created_by)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
 RETURNING id, created_at, version`
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
-	return m.DB.QueryRow(query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), m.SearchConfig, movie.CreatedBy}
+	// The movie and its first revision are inserted in a single transaction.
+	return withTx(m.DB, func(tx DBTX) error {
+		err := tx.QueryRow(query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			return err
+		}
+		return insertMovieRevision(tx, movie)
+	})
 }
 
 func (m MovieModel) Get(id int64) (*dto.Movie, error) {
@@ -73,9 +227,9 @@ func (m MovieModel) Get(id int64) (*dto.Movie, error) {
 	}
 	// Define the SQL query for retrieving the movie data.
 	query := `
-SELECT id, created_at, title, year, runtime, genres, version
+SELECT id, created_at, title, year, runtime, genres, version, COALESCE(created_by, 0)
 FROM movies
-WHERE id = $1`
+WHERE id = $1 AND deleted_at IS NULL`
 	// Declare a Movie struct to hold the data returned by the query.
 	var movie dto.Movie
 	err := m.DB.QueryRow(query, id).Scan(
@@ -86,6 +240,7 @@ WHERE id = $1`
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
+		&movie.CreatedBy,
 	)
 	if err != nil {
 		switch {
@@ -101,8 +256,8 @@ WHERE id = $1`
 func (m MovieModel) Update(movie *dto.Movie) error {
 	query := `
 UPDATE movies
-SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-WHERE id = $5 AND version = $6
+SET title = $1, year = $2, runtime = $3, genres = $4, search_config = $7, version = version + 1
+WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 RETURNING version`
 
 	// Create an args slice containing the values for the placeholder parameters.
@@ -113,20 +268,24 @@ RETURNING version`
 		pq.Array(movie.Genres),
 		movie.ID,
 		movie.Version,
+		m.SearchConfig,
 	}
-	// Execute the SQL query. If no matching row could be found, we know the movie
-	// version has changed (or the record has been deleted) and we return our custom
-	// ErrEditConflict error.
-	err := m.DB.QueryRow(query, args...).Scan(&movie.Version)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
-		default:
-			return err
+	return withTx(m.DB, func(tx DBTX) error {
+		// Execute the SQL query. If no matching row could be found, we know the movie
+		// version has changed (or the record has been deleted) and we return our custom
+		// ErrEditConflict error.
+		err := tx.QueryRow(query, args...).Scan(&movie.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
 		}
-	}
-	return nil
+		// Store the new version in the movie's history.
+		return insertMovieRevision(tx, movie)
+	})
 }
 
 func (m MovieModel) Delete(id int64) error {
@@ -134,10 +293,12 @@ func (m MovieModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// Construct the SQL query to delete the record.
+	// Construct the SQL query to move the record to the trash. It is only removed
+	// for good by Purge() once the retention window has passed.
 	query := `
-DELETE FROM movies
-WHERE id = $1`
+UPDATE movies
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL`
 	result, err := m.DB.Exec(query, id)
 	if err != nil {
 		return err
@@ -156,3 +317,110 @@ WHERE id = $1`
 	}
 	return nil
 }
+
+// The GetAllDeleted() method returns the movies in the trash.
+func (m MovieModel) GetAllDeleted(filters dto.Filters) ([]*dto.Movie, dto.Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, COALESCE(created_by, 0), deleted_at
+FROM movies
+WHERE deleted_at IS NOT NULL
+ORDER BY %s %s, id ASC
+LIMIT $1 OFFSET $2`, filters.SortColumn(), filters.SortDirection())
+	rows, err := m.DB.Query(query, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	movies := []*dto.Movie{}
+	for rows.Next() {
+		var movie dto.Movie
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.CreatedBy,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return nil, dto.Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	metadata := dto.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
+
+// The Restore() method takes a movie out of the trash and returns it.
+func (m MovieModel) Restore(id int64) (*dto.Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+UPDATE movies
+SET deleted_at = NULL, version = version + 1
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, created_at, title, year, runtime, genres, version, COALESCE(created_by, 0)`
+	var movie dto.Movie
+	err := withTx(m.DB, func(tx DBTX) error {
+		err := tx.QueryRow(query, id).Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.CreatedBy,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		return insertMovieRevision(tx, &movie)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+// The Purge() method permanently deletes the movies which were moved to the trash
+// before the given time, and returns how many were deleted.
+func (m MovieModel) Purge(before time.Time) (int64, error) {
+	query := `
+DELETE FROM movies
+WHERE deleted_at < $1`
+	result, err := m.DB.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// The Reindex() method rebuilds the search vector of every movie which was indexed
+// with a different text search configuration than the current one. It also fails if
+// the configuration doesn't exist, so it's a good check to run at startup.
+func (m MovieModel) Reindex() (int64, error) {
+	query := `
+UPDATE movies
+SET search_config = $1
+WHERE search_config <> $1::regconfig`
+	result, err := m.DB.Exec(query, m.SearchConfig)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
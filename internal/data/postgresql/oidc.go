@@ -0,0 +1,85 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+)
+
+type OIDCModel struct {
+	DB DBTX
+}
+
+func (m OIDCModel) InsertLogin(login *dto.OIDCLogin) error {
+	query := `
+INSERT INTO oidc_logins (state_hash, code_verifier, nonce, expiry)
+VALUES ($1, $2, $3, $4)`
+	_, err := m.DB.Exec(query, dto.HashState(login.State), login.Verifier, login.Nonce, login.Expiry)
+	return err
+}
+
+// The TakeLogin() method returns the unexpired login with the given state and deletes
+// it, so that each login can only be completed once.
+func (m OIDCModel) TakeLogin(state string) (*dto.OIDCLogin, error) {
+	query := `
+DELETE FROM oidc_logins
+WHERE state_hash = $1 AND expiry > NOW()
+RETURNING code_verifier, nonce, expiry`
+	login := dto.OIDCLogin{State: state, StateHash: dto.HashState(state)}
+	err := m.DB.QueryRow(query, login.StateHash).Scan(&login.Verifier, &login.Nonce, &login.Expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &login, nil
+}
+
+// The DeleteExpiredLogins() method removes the logins which were never completed.
+func (m OIDCModel) DeleteExpiredLogins() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM oidc_logins WHERE expiry <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// The GetUserForIdentity() method returns the user linked to the subject of an
+// identity provider.
+func (m OIDCModel) GetUserForIdentity(issuer, subject string) (*dto.User, error) {
+	query := `
+SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+FROM users
+INNER JOIN user_identities ON user_identities.user_id = users.id
+WHERE user_identities.issuer = $1 AND user_identities.subject = $2`
+	var user dto.User
+	err := m.DB.QueryRow(query, issuer, subject).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func (m OIDCModel) LinkIdentity(issuer, subject string, userID int64) error {
+	query := `
+INSERT INTO user_identities (issuer, subject, user_id)
+VALUES ($1, $2, $3)`
+	_, err := m.DB.Exec(query, issuer, subject, userID)
+	return err
+}
@@ -1,11 +1,11 @@
 package postgresql
 
 import (
-	"database/sql"
 	"errors"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"github.com/lib/pq"
 	"strings"
+	"time"
 )
 
 var (
@@ -13,18 +13,26 @@ var (
 )
 
 type PermissionModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // The GetAllForUser() method returns all permission codes for a specific user in a
-// Permissions slice
+// Permissions slice. These are the codes granted to the user directly together with
+// those of the roles assigned to them.
 func (m PermissionModel) GetAllForUser(userID int64) (dto.Permissions, error) {
 	query := `
 SELECT permissions.code
 FROM permissions
 INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
-INNER JOIN users ON users_permissions.user_id = users.id
-WHERE users.id = $1`
+WHERE users_permissions.user_id = $1
+AND (users_permissions.expires_at IS NULL OR users_permissions.expires_at > NOW())
+UNION
+SELECT permissions.code
+FROM permissions
+INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+WHERE users_roles.user_id = $1
+ORDER BY code`
 	rows, err := m.DB.Query(query, userID)
 	if err != nil {
 		return nil, err
@@ -45,58 +53,107 @@ WHERE users.id = $1`
 	return permissions, nil
 }
 
-func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+// The GetGrantsForUser() method returns the permissions granted directly to a user,
+// along with when they expire. Expired grants which haven't been swept yet are left
+// out.
+func (m PermissionModel) GetGrantsForUser(userID int64) ([]*dto.UserPermission, error) {
 	query := `
-INSERT INTO users_permissions
-SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
-	_, err := m.DB.Exec(query, userID, pq.Array(codes))
+SELECT permissions.code, users_permissions.expires_at
+FROM permissions
+INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+WHERE users_permissions.user_id = $1
+AND (users_permissions.expires_at IS NULL OR users_permissions.expires_at > NOW())
+ORDER BY permissions.code`
+	rows, err := m.DB.Query(query, userID)
 	if err != nil {
-		switch {
-		case strings.Contains(err.Error(), `violates foreign key constraint "users_permissions_user_id_fkey"`):
-			return ErrRecordNotFound
-		case strings.Contains(err.Error(), `violates unique constraint "users_permissions_pkey"`):
-			return ErrDuplicatePermission
-		default:
-			return err
+		return nil, err
+	}
+	defer rows.Close()
+	grants := []*dto.UserPermission{}
+	for rows.Next() {
+		var grant dto.UserPermission
+		err := rows.Scan(&grant.Code, &grant.ExpiresAt)
+		if err != nil {
+			return nil, err
 		}
+		grants = append(grants, &grant)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
-	return nil
+	return grants, nil
+}
+
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	return m.grant(userID, nil, codes)
+}
+
+// The AddForUserUntil() method grants permissions which lapse at the given time.
+func (m PermissionModel) AddForUserUntil(userID int64, expiresAt time.Time, codes ...string) error {
+	return m.grant(userID, &expiresAt, codes)
+}
+
+// A grant which has expired but hasn't been swept yet is replaced. Any other
+// existing grant is a duplicate, in which case nothing is granted.
+func (m PermissionModel) grant(userID int64, expiresAt *time.Time, codes []string) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+INSERT INTO users_permissions (user_id, permission_id, expires_at)
+SELECT $1, permissions.id, $3 FROM permissions WHERE permissions.code = ANY($2)
+ON CONFLICT (user_id, permission_id) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE users_permissions.expires_at <= NOW()`
+		result, err := tx.Exec(query, userID, pq.Array(codes), expiresAt)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `violates foreign key constraint "users_permissions_user_id_fkey"`):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(rowsAffected) < len(codes) {
+			return ErrDuplicatePermission
+		}
+		return nil
+	})
 }
 
 func (m PermissionModel) DeleteForUser(userID int64, codes ...string) error {
-	// Calling the Begin() method on the connection pool creates a new sql.Tx
-	// object, which represents the in-progress database transaction.
-	tx, err := m.DB.Begin()
-	if err != nil {
-		return err
-	}
-	query := `
+	// The withTx() helper runs the delete inside a transaction, so that nothing is
+	// deleted unless every code was found.
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
 DELETE FROM users_permissions
 WHERE user_id = $1
 AND permission_id = ANY(SELECT permissions.id FROM permissions WHERE permissions.code = ANY($2))`
+		result, err := tx.Exec(query, userID, pq.Array(codes))
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		// If less rows were affected, we know that the users_permissions table didn't
+		// contain a record we tried to delete. Returning an error rolls back the
+		// transaction.
+		if int(rowsAffected) < len(codes) {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
 
-	//Call Exec() on the transaction
-	result, err := tx.Exec(query, userID, pq.Array(codes))
+// The DeleteExpired() method removes the grants which have expired.
+func (m PermissionModel) DeleteExpired() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM users_permissions WHERE expires_at <= NOW()`)
 	if err != nil {
-		// If there is any error, we call the tx.Rollback() method on the
-		// transaction. This will abort the transaction and no changes will be
-		// made to the database.
-		tx.Rollback()
-		return err
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	// If less rows were affected, we know that the users_permissions table didn't contain a record
-	// we tried to delete it.
-	if int(rowsAffected) < len(codes) {
-		tx.Rollback()
-		return ErrRecordNotFound
+		return 0, err
 	}
-	// If there are no errors, the statements in the transaction can be committed
-	// to the database with the tx.Commit() method
-	err = tx.Commit()
-	return err
+	return result.RowsAffected()
 }
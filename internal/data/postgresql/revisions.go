@@ -0,0 +1,92 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/lib/pq"
+)
+
+type MovieRevisionModel struct {
+	DB DBTX
+}
+
+// insertMovieRevision() stores the current state of a movie as the revision for its
+// version. It is called by the MovieModel methods which change a movie, inside the
+// same transaction.
+func insertMovieRevision(db DBTX, movie *dto.Movie) error {
+	query := `
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres)
+VALUES ($1, $2, $3, $4, $5, $6)`
+	args := []interface{}{movie.ID, movie.Version, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	_, err := db.Exec(query, args...)
+	return err
+}
+
+func (m MovieRevisionModel) GetAllForMovie(movieID int64, filters dto.Filters) ([]*dto.MovieRevision, dto.Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), movie_id, version, created_at, title, year, runtime, genres
+FROM movie_revisions
+WHERE movie_id = $1
+ORDER BY %s %s
+LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+	rows, err := m.DB.Query(query, movieID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	revisions := []*dto.MovieRevision{}
+	for rows.Next() {
+		var revision dto.MovieRevision
+		err := rows.Scan(
+			&totalRecords,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.CreatedAt,
+			&revision.Title,
+			&revision.Year,
+			&revision.Runtime,
+			pq.Array(&revision.Genres),
+		)
+		if err != nil {
+			return nil, dto.Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dto.Metadata{}, err
+	}
+	metadata := dto.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+func (m MovieRevisionModel) Get(movieID int64, version int32) (*dto.MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT movie_id, version, created_at, title, year, runtime, genres
+FROM movie_revisions
+WHERE movie_id = $1 AND version = $2`
+	var revision dto.MovieRevision
+	err := m.DB.QueryRow(query, movieID, version).Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.CreatedAt,
+		&revision.Title,
+		&revision.Year,
+		&revision.Runtime,
+		pq.Array(&revision.Genres),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &revision, nil
+}
@@ -0,0 +1,239 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/lib/pq"
+	"strings"
+)
+
+var (
+	ErrDuplicateRole       = errors.New("duplicate role")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrRoleAlreadyAssigned = errors.New("role already assigned")
+)
+
+// roleColumns selects a role along with its sorted permission codes. It must be used
+// with "FROM roles" and grouped by roles.id.
+const roleColumns = `
+roles.id, roles.created_at, roles.name, roles.version,
+COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')`
+
+const rolePermissionsJoin = `
+LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+LEFT JOIN permissions ON permissions.id = roles_permissions.permission_id`
+
+type RoleModel struct {
+	DB DBTX
+}
+
+func (m RoleModel) GetAll() ([]*dto.Role, error) {
+	query := `SELECT ` + roleColumns + `
+FROM roles` + rolePermissionsJoin + `
+GROUP BY roles.id
+ORDER BY roles.name`
+	return m.query(query)
+}
+
+// The GetAllForUser() method returns the roles assigned to a user.
+func (m RoleModel) GetAllForUser(userID int64) ([]*dto.Role, error) {
+	query := `SELECT ` + roleColumns + `
+FROM roles
+INNER JOIN users_roles ON users_roles.role_id = roles.id` + rolePermissionsJoin + `
+WHERE users_roles.user_id = $1
+GROUP BY roles.id
+ORDER BY roles.name`
+	return m.query(query, userID)
+}
+
+// The GetByNames() method returns the roles with the given names, ignoring case.
+// Names which don't match a role are skipped.
+func (m RoleModel) GetByNames(names []string) ([]*dto.Role, error) {
+	query := `SELECT ` + roleColumns + `
+FROM roles` + rolePermissionsJoin + `
+WHERE roles.name = ANY($1::citext[])
+GROUP BY roles.id
+ORDER BY roles.name`
+	return m.query(query, pq.Array(names))
+}
+
+func (m RoleModel) query(query string, args ...interface{}) ([]*dto.Role, error) {
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []*dto.Role{}
+	for rows.Next() {
+		var role dto.Role
+		err := rows.Scan(
+			&role.ID,
+			&role.CreatedAt,
+			&role.Name,
+			&role.Version,
+			pq.Array(&role.Permissions),
+		)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (m RoleModel) Get(id int64) (*dto.Role, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT ` + roleColumns + `
+FROM roles` + rolePermissionsJoin + `
+WHERE roles.id = $1
+GROUP BY roles.id`
+	var role dto.Role
+	err := m.DB.QueryRow(query, id).Scan(
+		&role.ID,
+		&role.CreatedAt,
+		&role.Name,
+		&role.Version,
+		pq.Array(&role.Permissions),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &role, nil
+}
+
+func (m RoleModel) Insert(role *dto.Role) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+INSERT INTO roles (name)
+VALUES ($1)
+RETURNING id, created_at, version`
+		err := tx.QueryRow(query, role.Name).Scan(&role.ID, &role.CreatedAt, &role.Version)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `violates unique constraint "roles_name_key"`):
+				return ErrDuplicateRole
+			default:
+				return err
+			}
+		}
+		return setRolePermissions(tx, role)
+	})
+}
+
+// The Update() method renames a role and replaces its set of permissions.
+func (m RoleModel) Update(role *dto.Role) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+UPDATE roles
+SET name = $1, version = version + 1
+WHERE id = $2 AND version = $3
+RETURNING version`
+		err := tx.QueryRow(query, role.Name, role.ID, role.Version).Scan(&role.Version)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `violates unique constraint "roles_name_key"`):
+				return ErrDuplicateRole
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		_, err = tx.Exec(`DELETE FROM roles_permissions WHERE role_id = $1`, role.ID)
+		if err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role)
+	})
+}
+
+func setRolePermissions(tx DBTX, role *dto.Role) error {
+	query := `
+INSERT INTO roles_permissions
+SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+	_, err := tx.Exec(query, role.ID, pq.Array(role.Permissions))
+	return err
+}
+
+// The Delete() method removes a role, which also takes it away from every user it
+// was assigned to.
+func (m RoleModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	result, err := m.DB.Exec(`DELETE FROM roles WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The AddForUser() method assigns the named roles to a user. Nothing is assigned
+// unless every role exists and none of them is already assigned.
+func (m RoleModel) AddForUser(userID int64, names ...string) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+INSERT INTO users_roles
+SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2::citext[])`
+		result, err := tx.Exec(query, userID, pq.Array(names))
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `violates foreign key constraint "users_roles_user_id_fkey"`):
+				return ErrRecordNotFound
+			case strings.Contains(err.Error(), `violates unique constraint "users_roles_pkey"`):
+				return ErrRoleAlreadyAssigned
+			default:
+				return err
+			}
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(rowsAffected) < len(names) {
+			return ErrUnknownRole
+		}
+		return nil
+	})
+}
+
+// The DeleteForUser() method takes the named roles away from a user. Nothing is
+// removed unless every role was assigned to the user.
+func (m RoleModel) DeleteForUser(userID int64, names ...string) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+DELETE FROM users_roles
+WHERE user_id = $1
+AND role_id = ANY(SELECT roles.id FROM roles WHERE roles.name = ANY($2::citext[]))`
+		result, err := tx.Exec(query, userID, pq.Array(names))
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(rowsAffected) < len(names) {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
@@ -1,23 +1,44 @@
 package postgresql
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"github.com/kientink26/go-json-api/internal/data/dto"
 	"time"
 )
 
 // Define the TokenModel type.
 type TokenModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // The New() method is a shortcut which creates a new Token struct and then inserts the
 // data in the tokens table.
 func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*dto.Token, error) {
+	return m.NewWithData(userID, ttl, scope, "")
+}
+
+// The NewWithData() method is like New(), for scopes whose tokens carry extra data.
+func (m TokenModel) NewWithData(userID int64, ttl time.Duration, scope, data string) (*dto.Token, error) {
 	token, err := dto.GenerateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
+	token.Data = data
+	err = m.Insert(token)
+	return token, err
+}
+
+// The NewSession() method creates an authentication token for the client with the
+// given IP address and user agent.
+func (m TokenModel) NewSession(userID int64, ttl time.Duration, ip, userAgent string) (*dto.Token, error) {
+	token, err := dto.GenerateToken(userID, ttl, dto.ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.IP = ip
+	token.UserAgent = userAgent
 	err = m.Insert(token)
 	return token, err
 }
@@ -25,13 +46,163 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*dto.Tok
 // Insert() adds the data for a specific token to the tokens table.
 func (m TokenModel) Insert(token *dto.Token) error {
 	query := `
-INSERT INTO tokens (hash, user_id, expiry, scope)
-VALUES ($1, $2, $3, $4)`
-	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
+INSERT INTO tokens (hash, user_id, expiry, scope, data, ip, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope, token.Data, token.IP, token.UserAgent}
 	_, err := m.DB.Exec(query, args...)
 	return err
 }
 
+// The Get() method returns the unexpired token with the given scope and plaintext.
+func (m TokenModel) Get(scope, tokenPlaintext string) (*dto.Token, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+SELECT user_id, expiry, data
+FROM tokens
+WHERE hash = $1 AND scope = $2 AND expiry > $3`
+	token := dto.Token{Plaintext: tokenPlaintext, Hash: tokenHash[:], Scope: scope}
+	err := m.DB.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&token.UserID, &token.Expiry, &token.Data)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+// The GetForSession() method returns the session of an unexpired authentication
+// token, along with the user it belongs to. The time and IP address the session was
+// last used from are updated, at most once a minute to save on writes.
+func (m TokenModel) GetForSession(tokenPlaintext, ip string) (*dto.Session, *dto.User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+SELECT tokens.id, tokens.created_at, tokens.ip, tokens.user_agent, tokens.last_used_at, tokens.last_used_ip, tokens.expiry,
+	users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+FROM tokens
+INNER JOIN users ON users.id = tokens.user_id
+WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3 AND users.deleted_at IS NULL`
+	args := []interface{}{tokenHash[:], dto.ScopeAuthentication, time.Now()}
+	var session dto.Session
+	var user dto.User
+	err := m.DB.QueryRow(query, args...).Scan(
+		&session.ID,
+		&session.CreatedAt,
+		&session.IP,
+		&session.UserAgent,
+		&session.LastUsedAt,
+		&session.LastUsedIP,
+		&session.Expiry,
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	if session.LastUsedAt == nil || time.Since(*session.LastUsedAt) > time.Minute {
+		query = `
+UPDATE tokens
+SET last_used_at = NOW(), last_used_ip = $2
+WHERE id = $1
+RETURNING last_used_at`
+		err = m.DB.QueryRow(query, session.ID, ip).Scan(&session.LastUsedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		session.LastUsedIP = ip
+	}
+	return &session, &user, nil
+}
+
+// The GetAllSessionsForUser() method returns the unexpired sessions of a user, the
+// most recently used first.
+func (m TokenModel) GetAllSessionsForUser(userID int64) ([]*dto.Session, error) {
+	query := `
+SELECT id, created_at, ip, user_agent, last_used_at, last_used_ip, expiry
+FROM tokens
+WHERE user_id = $1 AND scope = $2 AND expiry > $3
+ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC`
+	rows, err := m.DB.Query(query, userID, dto.ScopeAuthentication, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*dto.Session{}
+	for rows.Next() {
+		var session dto.Session
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.IP, &session.UserAgent, &session.LastUsedAt, &session.LastUsedIP, &session.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// The DeleteSession() method logs out one of a user's sessions.
+func (m TokenModel) DeleteSession(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+DELETE FROM tokens
+WHERE id = $1 AND user_id = $2 AND scope = $3`
+	result, err := m.DB.Exec(query, id, userID, dto.ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The GetAllForUser() method describes the unexpired tokens of a user.
+func (m TokenModel) GetAllForUser(userID int64) ([]*dto.TokenMetadata, error) {
+	query := `
+SELECT scope, expiry
+FROM tokens
+WHERE user_id = $1 AND expiry > $2
+ORDER BY expiry`
+	rows, err := m.DB.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []*dto.TokenMetadata{}
+	for rows.Next() {
+		var token dto.TokenMetadata
+		err := rows.Scan(&token.Scope, &token.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
 // DeleteAllForUser() deletes all tokens for a specific user and scope.
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	query := `
@@ -0,0 +1,158 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/kientink26/go-json-api/internal/data/dto"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTOTPEnabled = errors.New("two-factor authentication already enabled")
+)
+
+type TOTPModel struct {
+	DB DBTX
+}
+
+// The Get() method returns the TOTP secret of a user, whether or not it has been
+// confirmed.
+func (m TOTPModel) Get(userID int64) (*dto.TOTP, error) {
+	query := `
+SELECT user_id, secret, created_at, confirmed_at, last_step
+FROM user_totp
+WHERE user_id = $1`
+	var t dto.TOTP
+	err := m.DB.QueryRow(query, userID).Scan(&t.UserID, &t.Secret, &t.CreatedAt, &t.ConfirmedAt, &t.LastStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// The Enroll() method saves a new unconfirmed secret for a user, replacing any
+// earlier unconfirmed one. It returns ErrTOTPEnabled if the user has already
+// confirmed a secret.
+func (m TOTPModel) Enroll(t *dto.TOTP) error {
+	query := `
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = NOW(), last_step = 0
+WHERE user_totp.confirmed_at IS NULL
+RETURNING created_at`
+	err := m.DB.QueryRow(query, t.UserID, t.Secret).Scan(&t.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrTOTPEnabled
+		default:
+			return err
+		}
+	}
+	t.ConfirmedAt = nil
+	t.LastStep = 0
+	return nil
+}
+
+// The Confirm() method enables a user's secret and replaces their recovery codes.
+func (m TOTPModel) Confirm(t *dto.TOTP, step int64, recoveryHashes [][]byte) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		query := `
+UPDATE user_totp
+SET confirmed_at = NOW(), last_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL
+RETURNING confirmed_at, last_step`
+		err := tx.QueryRow(query, t.UserID, step).Scan(&t.ConfirmedAt, &t.LastStep)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrTOTPEnabled
+			default:
+				return err
+			}
+		}
+		_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, t.UserID)
+		if err != nil {
+			return err
+		}
+		query = `
+INSERT INTO recovery_codes (user_id, hash)
+SELECT $1, unnest($2::bytea[])`
+		_, err = tx.Exec(query, t.UserID, pq.Array(recoveryHashes))
+		return err
+	})
+}
+
+// The UseStep() method records that the code for a step has been accepted. It
+// returns false if a code for the same or a later step was accepted first, so that
+// two requests racing with the same code can't both succeed.
+func (m TOTPModel) UseStep(userID, step int64) (bool, error) {
+	query := `
+UPDATE user_totp
+SET last_step = $2
+WHERE user_id = $1 AND last_step < $2`
+	result, err := m.DB.Exec(query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// The UseRecoveryCode() method marks a user's unused recovery code as used. It
+// returns false if there is no such code.
+func (m TOTPModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	query := `
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND hash = $2 AND used_at IS NULL`
+	result, err := m.DB.Exec(query, userID, dto.HashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// The CountRecoveryCodes() method returns the number of unused recovery codes a user
+// has left.
+func (m TOTPModel) CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := m.DB.QueryRow(`SELECT count(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// The Delete() method turns off two-factor authentication for a user, removing their
+// secret and recovery codes.
+func (m TOTPModel) Delete(userID int64) error {
+	return withTx(m.DB, func(tx DBTX) error {
+		_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
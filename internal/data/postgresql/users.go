@@ -14,7 +14,7 @@ var (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func (m UserModel) GetAll(name string, email string, filters dto.Filters) ([]*dto.User, dto.Metadata, error) {
@@ -84,11 +84,40 @@ RETURNING id, created_at, version`
 	return nil
 }
 
+func (m UserModel) Get(id int64) (*dto.User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT id, created_at, name, email, password_hash, activated, version
+FROM users
+WHERE id = $1`
+	var user dto.User
+	err := m.DB.QueryRow(query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
 func (m UserModel) GetByEmail(email string) (*dto.User, error) {
 	query := `
 SELECT id, created_at, name, email, password_hash, activated, version
 FROM users
-WHERE email = $1`
+WHERE email = $1 AND deleted_at IS NULL`
 	var user dto.User
 	err := m.DB.QueryRow(query, email).Scan(
 		&user.ID,
@@ -138,6 +167,133 @@ RETURNING version`
 	return nil
 }
 
+// The ScheduleDeletion() method schedules a user to be anonymized at the given time.
+func (m UserModel) ScheduleDeletion(id int64, at time.Time) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+UPDATE users
+SET deletion_scheduled_for = $2
+WHERE id = $1 AND deleted_at IS NULL`
+	result, err := m.DB.Exec(query, id, at)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The CancelDeletion() method stops a user from being anonymized. It returns
+// ErrRecordNotFound if their deletion wasn't scheduled.
+func (m UserModel) CancelDeletion(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+UPDATE users
+SET deletion_scheduled_for = NULL
+WHERE id = $1 AND deletion_scheduled_for IS NOT NULL AND deleted_at IS NULL`
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// The GetDueForDeletion() method returns the IDs of the users whose grace period for
+// deleting their account has passed.
+func (m UserModel) GetDueForDeletion() ([]int64, error) {
+	query := `
+SELECT id
+FROM users
+WHERE deletion_scheduled_for <= NOW() AND deleted_at IS NULL
+ORDER BY id`
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// The Anonymize() method scrubs the personal data from a user whose deletion is due,
+// so that their comments can stay attributed to the row, and removes everything else
+// which belongs to them, along with the failed logins for their email address. The
+// password hash is emptied, which no hasher recognizes. It returns ErrRecordNotFound
+// if the deletion was cancelled.
+func (m UserModel) Anonymize(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	return withTx(m.DB, func(tx DBTX) error {
+		// The old email address is needed to forget its failed logins.
+		var email string
+		err := tx.QueryRow(`SELECT email FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&email)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		query := `
+UPDATE users
+SET name = $2, email = 'deleted-' || id || '@deleted.invalid', password_hash = '', activated = false,
+	deletion_scheduled_for = NULL, deleted_at = NOW(), version = version + 1
+WHERE id = $1 AND deletion_scheduled_for <= NOW() AND deleted_at IS NULL`
+		result, err := tx.Exec(query, id, dto.DeletedUserName)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		_, err = tx.Exec(`DELETE FROM login_failures WHERE scope = $1 AND key = $2`, dto.LoginFailureScopeEmail, dto.LoginFailureEmailKey(email))
+		if err != nil {
+			return err
+		}
+		tables := []string{"tokens", "api_keys", "users_permissions", "users_roles", "user_totp",
+			"recovery_codes", "user_identities", "data_exports"}
+		for _, table := range tables {
+			_, err = tx.Exec(`DELETE FROM `+table+` WHERE user_id = $1`, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*dto.User, error) {
 	// Calculate the SHA-256 hash of the plaintext token provided by the client.
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
@@ -0,0 +1,90 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"Replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"Add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"Remove member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"Replace array", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"Nested object", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"title":"Moana","genres":["animation","adventure"],"version":1}`
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{"Add to array", `[{"op":"add","path":"/genres/-","value":"family"}]`, `{"title":"Moana","genres":["animation","adventure","family"],"version":1}`, nil},
+		{"Insert into array", `[{"op":"add","path":"/genres/0","value":"family"}]`, `{"title":"Moana","genres":["family","animation","adventure"],"version":1}`, nil},
+		{"Remove from array", `[{"op":"remove","path":"/genres/0"}]`, `{"title":"Moana","genres":["adventure"],"version":1}`, nil},
+		{"Replace", `[{"op":"replace","path":"/title","value":"Moana 2"}]`, `{"title":"Moana 2","genres":["animation","adventure"],"version":1}`, nil},
+		{"Replace with null", `[{"op":"add","path":"/runtime","value":107},{"op":"replace","path":"/runtime","value":null}]`, `{"title":"Moana","runtime":null,"genres":["animation","adventure"],"version":1}`, nil},
+		{"Missing value", `[{"op":"add","path":"/year"}]`, "", ErrInvalidPatch},
+		{"Move", `[{"op":"move","from":"/genres/1","path":"/genres/0"}]`, `{"title":"Moana","genres":["adventure","animation"],"version":1}`, nil},
+		{"Copy", `[{"op":"copy","from":"/title","path":"/name"}]`, `{"title":"Moana","name":"Moana","genres":["animation","adventure"],"version":1}`, nil},
+		{"Passing test", `[{"op":"test","path":"/version","value":1},{"op":"remove","path":"/title"}]`, `{"genres":["animation","adventure"],"version":1}`, nil},
+		{"Failing test", `[{"op":"test","path":"/version","value":2},{"op":"remove","path":"/title"}]`, "", ErrTestFailed},
+		{"Missing path", `[{"op":"remove","path":"/year"}]`, "", ErrInvalidPatch},
+		{"Index out of range", `[{"op":"replace","path":"/genres/2","value":"family"}]`, "", ErrInvalidPatch},
+		{"Unknown operation", `[{"op":"frobnicate","path":"/title"}]`, "", ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(tt.patch), &ops); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply([]byte(doc), ops)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("want error %v; got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(g)
+	wantJSON, _ := json.Marshal(w)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("want %s; got %s", wantJSON, gotJSON)
+	}
+}
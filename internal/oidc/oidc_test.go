@@ -0,0 +1,177 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIdP is an in-process stand-in identity provider, serving the discovery
+// document, its signing keys and a token endpoint which hands out the configured
+// claims for a single authorization code.
+type testIdP struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, code: "test-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != idp.code || Challenge(r.PostForm.Get("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, idp.claims)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *testIdP) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            idp.URL,
+		"sub":            "user-123",
+		"aud":            "greenlight",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          "test-nonce",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newTestIdP(t)
+	p := New(idp.URL, "greenlight", "", "https://app.example.com/callback")
+	authURL, err := p.AuthCodeURL("test-state", "test-nonce", "test-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("state") != "test-state" || q.Get("nonce") != "test-nonce" {
+		t.Errorf("unexpected authorization URL %s", authURL)
+	}
+	if q.Get("code_challenge") != Challenge("test-verifier") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("want PKCE challenge in authorization URL %s", authURL)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newTestIdP(t)
+	tests := []struct {
+		name     string
+		modify   func(claims map[string]interface{})
+		code     string
+		verifier string
+		wantErr  error
+	}{
+		{"Valid", func(map[string]interface{}) {}, "test-code", "test-verifier", nil},
+		{"Audience array", func(c map[string]interface{}) { c["aud"] = []string{"other", "greenlight"} }, "test-code", "test-verifier", nil},
+		{"Wrong code", func(map[string]interface{}) {}, "bad-code", "test-verifier", ErrExchangeFailed},
+		{"Wrong verifier", func(map[string]interface{}) {}, "test-code", "bad-verifier", ErrExchangeFailed},
+		{"Wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, "test-code", "test-verifier", ErrInvalidToken},
+		{"Wrong audience", func(c map[string]interface{}) { c["aud"] = "other" }, "test-code", "test-verifier", ErrInvalidToken},
+		{"Expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "test-code", "test-verifier", ErrInvalidToken},
+		{"Wrong nonce", func(c map[string]interface{}) { c["nonce"] = "replayed" }, "test-code", "test-verifier", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.validClaims()
+			tt.modify(claims)
+			idp.claims = claims
+			idp.challenge = Challenge("test-verifier")
+			p := New(idp.URL, "greenlight", "secret", "https://app.example.com/callback")
+			got, err := p.Exchange(tt.code, tt.verifier, "test-nonce")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("want error %v; got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "user-123" || got.Email != "alice@example.com" || !got.EmailVerified {
+				t.Errorf("unexpected claims %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedToken(t *testing.T) {
+	idp := newTestIdP(t)
+	p := New(idp.URL, "greenlight", "", "")
+	token := idp.sign(t, idp.validClaims())
+	// Swap in a payload claiming another subject, keeping the original signature.
+	claims := idp.validClaims()
+	claims["sub"] = "admin"
+	forged := idp.sign(t, claims)
+	tampered := forged[:strings.LastIndex(forged, ".")] + token[strings.LastIndex(token, "."):]
+	if _, err := p.Verify(token, "test-nonce"); err != nil {
+		t.Fatalf("want valid token; got %v", err)
+	}
+	if _, err := p.Verify(tampered, "test-nonce"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("want ErrInvalidToken; got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Corpus is a local copy of a breached password corpus in the k-anonymity range
+// format of Have I Been Pwned. Dir holds one file per 5-character prefix of the
+// uppercase hex SHA-1 hashes, such as 5BAA6.txt, with a line for each hash with that
+// prefix: the remaining 35 characters, a colon and the number of times it was seen.
+// Only the file for a password's prefix is read when it is checked, so the corpus
+// doesn't have to fit in memory.
+type Corpus struct {
+	Dir string
+}
+
+// Contains reports whether the password appears in the corpus. Lines with a count of
+// 0, which are padding, are ignored.
+func (c *Corpus) Contains(plaintext string) (bool, error) {
+	sum := sha1.Sum([]byte(plaintext))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	f, err := os.Open(filepath.Join(c.Dir, hash[:5]+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(suffix, hash[5:]) {
+			return strings.TrimLeft(count, "0") != "", nil
+		}
+	}
+	return false, scanner.Err()
+}
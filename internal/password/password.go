@@ -0,0 +1,75 @@
+// Package password hashes and verifies passwords. Hashes are stored as strings in
+// the PHC format, such as $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, so that each
+// one records the algorithm and parameters which made it. This lets the parameters
+// change over time: old hashes can still be verified, and NeedsRehash() reports
+// which ones should be replaced.
+package password
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUnknownFormat is returned when a hash isn't in the format of any known hasher.
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Hasher is a password hashing algorithm with a particular set of parameters.
+type Hasher interface {
+	// Hash returns the encoded hash of a password.
+	Hash(plaintext string) (string, error)
+	// Verify reports whether the password matches an encoded hash in the format of
+	// this hasher, using the parameters recorded in the hash.
+	Verify(plaintext, encoded string) (bool, error)
+	// Recognizes reports whether an encoded hash is in the format of this hasher.
+	Recognizes(encoded string) bool
+	// Current reports whether an encoded hash was made by this hasher with its
+	// current parameters.
+	Current(encoded string) bool
+	// MaxLength returns the longest password in bytes which the hasher accepts, or 0
+	// if there is no limit.
+	MaxLength() int
+}
+
+var (
+	// Default is used to hash new passwords.
+	Default Hasher = DefaultArgon2id
+	// Legacy are the other hashers used to verify existing hashes. Only the format of
+	// a hash matters for verifying it, so their parameters aren't used.
+	Legacy = []Hasher{DefaultArgon2id, Bcrypt{Cost: 12}}
+)
+
+// Hash returns the hash of a password made by the default hasher.
+func Hash(plaintext string) (string, error) {
+	return Default.Hash(plaintext)
+}
+
+// Verify reports whether the password matches an encoded hash made by the default
+// hasher or one of the legacy ones.
+func Verify(plaintext, encoded string) (bool, error) {
+	for _, h := range append([]Hasher{Default}, Legacy...) {
+		if h.Recognizes(encoded) {
+			return h.Verify(plaintext, encoded)
+		}
+	}
+	return false, ErrUnknownFormat
+}
+
+// NeedsRehash reports whether an encoded hash should be replaced with one made by the
+// default hasher, because it uses another algorithm or outdated parameters.
+func NeedsRehash(encoded string) bool {
+	return !Default.Current(encoded)
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// SimulateVerify does the same work as verifying a password against a hash made by
+// the default hasher, without a real hash to check it against.
+func SimulateVerify(plaintext string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = Default.Hash("dummy password")
+	})
+	Default.Verify(plaintext, dummyHash)
+}
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+// Parameters which keep the tests fast.
+var testArgon2id = Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	encoded, err := testArgon2id.Hash("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash %s", encoded)
+	}
+	tests := []struct {
+		name      string
+		plaintext string
+		want      bool
+	}{
+		{"Match", "pa55word", true},
+		{"Mismatch", "pa55wore", false},
+		{"Empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testArgon2id.Verify(tt.plaintext, encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyAndRehash(t *testing.T) {
+	defer func(h Hasher) { Default = h }(Default)
+	Default = testArgon2id
+	bcryptHash, err := Bcrypt{Cost: 4}.Hash("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := Hash("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker := Argon2id{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	outdated, err := weaker.Hash("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		encoded     string
+		needsRehash bool
+	}{
+		{"Current argon2id", current, false},
+		{"Outdated argon2id", outdated, true},
+		{"Legacy bcrypt", bcryptHash, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := Verify("pa55word", tt.encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Error("want password to match")
+			}
+			if got := NeedsRehash(tt.encoded); got != tt.needsRehash {
+				t.Errorf("want NeedsRehash %t; got %t", tt.needsRehash, got)
+			}
+		})
+	}
+	if _, err := Verify("pa55word", "$md5$nope"); err != ErrUnknownFormat {
+		t.Errorf("want ErrUnknownFormat; got %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Score estimates how hard a password is to guess, in the manner of zxcvbn, from 0
+// (too guessable) to 4 (very unguessable). The password is split into the cheapest
+// sequence of patterns which an attacker would try: common passwords and words
+// (including the user's own details, passed as userInputs), spelled backwards or
+// with l33t substitutions, keyboard runs, sequences, repeats and years, with any
+// other characters guessed by brute force. The score comes from the estimated number
+// of guesses for that sequence.
+func Score(plaintext string, userInputs ...string) int {
+	guesses := log10Guesses(plaintext, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+var (
+	//go:embed common.txt
+	commonPasswords string
+	//go:embed words.txt
+	commonWords string
+
+	// The rank of each common password and word, the most common being 1.
+	ranked = rankWords(commonPasswords, commonWords)
+)
+
+func rankWords(lists ...string) map[string]int {
+	ranks := make(map[string]int)
+	rank := 1
+	for _, list := range lists {
+		for _, word := range strings.Fields(list) {
+			if _, ok := ranks[word]; !ok {
+				ranks[word] = rank
+				rank++
+			}
+		}
+	}
+	return ranks
+}
+
+var (
+	keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./", "~!@#$%^&*()_+"}
+	// Common l33t substitutions, each undone to a single letter.
+	leet = map[rune]rune{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'}
+)
+
+// match is a pattern found in the password, covering the runes [i, j).
+type match struct {
+	i, j    int
+	guesses float64 // log10 of the number of guesses
+}
+
+// The minimum number of guesses for any pattern longer than one character, so that
+// splitting a password into many cheap patterns doesn't make it look weaker than it
+// is.
+const minPatternGuesses = 1.0 // log10(10)
+
+func log10Guesses(plaintext string, userInputs []string) float64 {
+	runes := []rune(plaintext)
+	if len(runes) == 0 {
+		return 0
+	}
+	lower := []rune(strings.ToLower(plaintext))
+	matches := dictionaryMatches(runes, lower, userWords(userInputs))
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, repeatMatches(lower)...)
+	matches = append(matches, yearMatches(lower)...)
+
+	// best[j] is the fewest guesses for the first j runes, found by trying each way
+	// of reaching j: a brute-forced rune, or a pattern ending there.
+	bruteforce := math.Log10(cardinality(runes))
+	best := make([]float64, len(runes)+1)
+	for j := 1; j <= len(runes); j++ {
+		best[j] = best[j-1] + bruteforce
+		for _, m := range matches {
+			if m.j == j {
+				if g := best[m.i] + math.Max(m.guesses, minPatternGuesses); g < best[j] {
+					best[j] = g
+				}
+			}
+		}
+	}
+	return best[len(runes)]
+}
+
+// cardinality returns the size of the character set a brute-force attack on the
+// password would need.
+func cardinality(runes []rune) float64 {
+	var lower, upper, digits, symbols, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digits = true
+		case r < unicode.MaxASCII:
+			symbols = true
+		default:
+			other = true
+		}
+	}
+	n := 0.0
+	for _, set := range []struct {
+		used bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digits, 10}, {symbols, 33}, {other, 100}} {
+		if set.used {
+			n += set.size
+		}
+	}
+	return n
+}
+
+// userWords returns the words in the user's own details, such as their name and the
+// parts of their email address, which are as easy to guess as the commonest
+// passwords.
+func userWords(userInputs []string) map[string]bool {
+	words := make(map[string]bool)
+	for _, input := range userInputs {
+		fields := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, field := range fields {
+			if len([]rune(field)) >= 3 {
+				words[field] = true
+			}
+		}
+	}
+	return words
+}
+
+func dictionaryMatches(runes, lower []rune, user map[string]bool) []match {
+	unleet := make([]rune, len(lower))
+	for i, r := range lower {
+		if l, ok := leet[r]; ok {
+			unleet[i] = l
+		} else {
+			unleet[i] = r
+		}
+	}
+	var matches []match
+	for i := range lower {
+		for j := i + 3; j <= len(lower); j++ {
+			word := string(lower[i:j])
+			candidates := []struct {
+				word   string
+				factor float64
+			}{
+				{word, 0},
+				{reverse(word), math.Log10(2)},
+				{string(unleet[i:j]), math.Log10(2)},
+			}
+			for _, c := range candidates {
+				rank, ok := ranked[c.word]
+				if user[c.word] {
+					rank, ok = 1, true
+				}
+				if !ok {
+					continue
+				}
+				guesses := math.Log10(float64(rank)) + c.factor + uppercaseFactor(runes[i:j])
+				matches = append(matches, match{i, j, guesses})
+			}
+		}
+	}
+	return matches
+}
+
+// uppercaseFactor returns the extra guesses (as a log10) needed for the
+// capitalisation of a word, which are few for the common styles.
+func uppercaseFactor(word []rune) float64 {
+	var upper, lower int
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0 || (upper == 1 && unicode.IsUpper(word[0])) || (upper == 1 && unicode.IsUpper(word[len(word)-1])):
+		return math.Log10(2)
+	default:
+		return float64(upper) * math.Log10(2)
+	}
+}
+
+// sequenceMatches finds runs of at least three letters or digits which go up or down
+// by one, such as abcd or 9876.
+func sequenceMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i < len(lower)-2; {
+		delta := lower[i+1] - lower[i]
+		j := i + 1
+		for j < len(lower) && (delta == 1 || delta == -1) && lower[j]-lower[j-1] == delta && sameClass(lower[j], lower[i]) {
+			j++
+		}
+		if j-i >= 3 {
+			base := 26.0
+			if unicode.IsDigit(lower[i]) {
+				base = 10
+			}
+			if strings.ContainsRune("a1z9", lower[i]) {
+				base = 4
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, match{i, j, math.Log10(base * float64(j-i))})
+			i = j
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	return (unicode.IsDigit(a) && unicode.IsDigit(b)) || (unicode.IsLetter(a) && unicode.IsLetter(b))
+}
+
+// keyboardMatches finds runs of at least four keys next to each other on a row of a
+// QWERTY keyboard, either way along it.
+func keyboardMatches(lower []rune) []match {
+	var matches []match
+	for i := range lower {
+		longest := 0
+		for j := i + 4; j <= len(lower); j++ {
+			run := string(lower[i:j])
+			for _, row := range keyboardRows {
+				if strings.Contains(row, run) || strings.Contains(row, reverse(run)) {
+					longest = j
+				}
+			}
+			if longest != j {
+				break
+			}
+		}
+		if longest > 0 {
+			matches = append(matches, match{i, longest, math.Log10(40 * float64(longest-i))})
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds a block of characters repeated at least twice in a row, such as
+// aaaa or abcabc. Guessing it means guessing the block and the number of repeats.
+func repeatMatches(lower []rune) []match {
+	var matches []match
+	for i := range lower {
+		for size := 1; i+2*size <= len(lower); size++ {
+			block := string(lower[i : i+size])
+			j := i + size
+			for j+size <= len(lower) && string(lower[j:j+size]) == block {
+				j += size
+			}
+			if j-i >= 2*size && j-i >= 3 {
+				guesses := float64(size)*math.Log10(cardinality(lower[i:i+size])) + math.Log10(float64((j-i)/size))
+				matches = append(matches, match{i, j, guesses})
+			}
+		}
+	}
+	return matches
+}
+
+// yearMatches finds recent years, which are popular in passwords.
+func yearMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i+4 <= len(lower); i++ {
+		year := string(lower[i : i+4])
+		if year >= "1900" && year <= "2049" && strings.Trim(year, "0123456789") == "" {
+			matches = append(matches, match{i, i + 4, math.Log10(150)})
+		}
+	}
+	return matches
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,64 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		plaintext  string
+		userInputs []string
+		max        int
+		min        int
+	}{
+		{"password", nil, 0, 0},
+		{"P@ssw0rd", nil, 1, 0},
+		{"drowssap", nil, 1, 0},
+		{"abcdefghij", nil, 1, 0},
+		{"qwertyuiop", nil, 1, 0},
+		{"aaaaaaaaaaaa", nil, 1, 0},
+		{"Summer2024!", nil, 2, 0},
+		{"alice1990", []string{"Alice Smith", "alice@example.com"}, 1, 0},
+		{"jK8#qv2LpZ", nil, 4, 3},
+		{"correct horse battery staple", nil, 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plaintext, func(t *testing.T) {
+			got := Score(tt.plaintext, tt.userInputs...)
+			if got < tt.min || got > tt.max {
+				t.Errorf("want score between %d and %d; got %d", tt.min, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	dir := t.TempDir()
+	// The SHA-1 hash of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8, and
+	// the 0 count line is padding for a hash which was never seen.
+	range5BAA6 := "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n011053FD0102E94D6AE2F8B83D76FAF94F6:0\r\n"
+	err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(range5BAA6), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Corpus{Dir: dir}
+	tests := []struct {
+		plaintext string
+		want      bool
+	}{
+		{"password", true},
+		{"Password", false},
+		{"correct horse battery staple", false},
+	}
+	for _, tt := range tests {
+		got, err := c.Contains(tt.plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: want %t; got %t", tt.plaintext, tt.want, got)
+		}
+	}
+}
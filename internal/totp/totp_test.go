@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238 appendix B, truncated to six digits.
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d: want %s; got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{"Current period", 0, true},
+		{"Previous period", -Period, true},
+		{"Next period", Period, true},
+		{"Too old", -2 * Period, false},
+		{"Too new", 2 * Period, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(secret, Step(now.Add(tt.offset)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, ok, err := Validate(code, secret, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Fatalf("want %t; got %t", tt.want, ok)
+			}
+			if ok && step != Step(now.Add(tt.offset)) {
+				t.Errorf("want step %d; got %d", Step(now.Add(tt.offset)), step)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("JBSWY3DPEHPK3PXP", "Greenlight", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Greenlight:alice@example.com" {
+		t.Errorf("unexpected URI %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Greenlight" || q.Get("digits") != "6" {
+		t.Errorf("unexpected parameters %v", q)
+	}
+}
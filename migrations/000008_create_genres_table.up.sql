@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name citext UNIQUE NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+-- Seed the catalog with the genres already used by existing movies, taking the most
+-- used spelling of each one which differs only in case.
+INSERT INTO genres (name)
+SELECT DISTINCT ON (lower(genre)) genre
+FROM (SELECT unnest(genres) AS genre FROM movies) AS used
+GROUP BY genre
+ORDER BY lower(genre), count(*) DESC, genre
+ON CONFLICT DO NOTHING;
+-- Rewrite the genres of existing movies to the catalog spelling, so that searching by
+-- genre finds them all, and drop the duplicates which that makes.
+UPDATE movies
+SET genres = catalog.genres, version = movies.version + 1
+FROM (
+    SELECT movies.id, ARRAY(
+        SELECT genres.name::text
+        FROM unnest(movies.genres) WITH ORDINALITY AS used(genre, position)
+        INNER JOIN genres ON genres.name = used.genre::citext
+        GROUP BY genres.name
+        ORDER BY min(used.position)
+    ) AS genres
+    FROM movies
+) AS catalog
+WHERE movies.id = catalog.id AND movies.genres <> catalog.genres;
@@ -0,0 +1,3 @@
+-- A NULL expires_at means that the grant is permanent.
+ALTER TABLE users_permissions ADD COLUMN IF NOT EXISTS expires_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS users_permissions_expires_at_idx ON users_permissions (expires_at);
@@ -0,0 +1,13 @@
+-- Movies added before ownership was recorded, or whose owner has been deleted, have
+-- no owner.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS created_by bigint REFERENCES users ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS movies_created_by_idx ON movies (created_by);
+-- Add the permission to change one's own movies, and a role for contributors.
+INSERT INTO permissions (code)
+VALUES ('movies:write:own');
+INSERT INTO roles (name)
+VALUES ('contributor');
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id
+FROM roles, permissions
+WHERE roles.name = 'contributor' AND permissions.code IN ('movies:write:own', 'comments:write');
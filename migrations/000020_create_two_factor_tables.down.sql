@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'users:write';
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
@@ -0,0 +1,23 @@
+-- Holds each user's TOTP secret. The secret is only used for logging in once it has
+-- been confirmed with a code, and last_step is the step of the last accepted code,
+-- which stops a code from being used twice.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    secret text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    confirmed_at timestamp(0) with time zone,
+    last_step bigint NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    hash bytea NOT NULL,
+    used_at timestamp(0) with time zone,
+    PRIMARY KEY (user_id, hash)
+);
+-- Add the permission to manage other users' accounts, which admins get.
+INSERT INTO permissions (code)
+VALUES ('users:write');
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id
+FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'users:write';
@@ -0,0 +1,11 @@
+-- Counts the recent failed logins for each email address and each client IP. The
+-- email addresses don't have to belong to a user, so that the responses are the same
+-- whether or not they do.
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope text NOT NULL,
+    key text NOT NULL,
+    failures integer NOT NULL DEFAULT 1,
+    last_failed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    locked_until timestamp(0) with time zone,
+    PRIMARY KEY (scope, key)
+);
@@ -0,0 +1,2 @@
+-- Extra data carried by a token, such as the new address for an email change.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS data text NOT NULL DEFAULT '';
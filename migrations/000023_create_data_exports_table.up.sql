@@ -0,0 +1,11 @@
+-- Archives of a user's personal data, which are assembled in the background and can
+-- be downloaded until they expire.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    status text NOT NULL DEFAULT 'pending',
+    archive bytea,
+    expiry timestamp(0) with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS data_exports_user_id_idx ON data_exports (user_id);
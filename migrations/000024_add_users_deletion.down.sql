@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_deletion_scheduled_for_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_for;
@@ -0,0 +1,6 @@
+-- A user who deletes their account is anonymized once deletion_scheduled_for has
+-- passed, unless they cancel it first. Their comments are kept, and deleted_at marks
+-- the scrubbed row they remain attributed to.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_for timestamp(0) with time zone;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_for_idx ON users (deletion_scheduled_for);